@@ -0,0 +1,149 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Named sets of SSH algorithms which can be used in ClientConfig.AlgorithmPreset
+const (
+	// AlgorithmsModern offers only AEAD ciphers, ETM MACs and modern key exchanges
+	// which is suitable for servers with a hardened configuration.
+	AlgorithmsModern = "modern"
+
+	// AlgorithmsCompatible offers every algorithm golang.org/x/crypto/ssh implements
+	// without known security issues.
+	AlgorithmsCompatible = "compatible"
+
+	// AlgorithmsLegacy adds algorithms with known security issues (such as diffie-hellman-group14-sha1
+	// and ssh-rsa) to AlgorithmsCompatible. Only use this for servers which support nothing else.
+	AlgorithmsLegacy = "legacy"
+)
+
+var (
+	modernAlgorithms = ssh.Algorithms{
+		Ciphers: []string{
+			ssh.CipherChaCha20Poly1305,
+			ssh.CipherAES256GCM,
+			ssh.CipherAES128GCM,
+		},
+		KeyExchanges: []string{
+			ssh.KeyExchangeMLKEM768X25519,
+			ssh.KeyExchangeCurve25519,
+			ssh.KeyExchangeECDHP384,
+			ssh.KeyExchangeECDHP521,
+			ssh.KeyExchangeDH16SHA512,
+		},
+		MACs: []string{
+			ssh.HMACSHA512ETM,
+			ssh.HMACSHA256ETM,
+		},
+		HostKeys: []string{
+			ssh.KeyAlgoED25519,
+			ssh.KeyAlgoECDSA521,
+			ssh.KeyAlgoECDSA384,
+			ssh.KeyAlgoECDSA256,
+			ssh.KeyAlgoRSASHA512,
+			ssh.KeyAlgoRSASHA256,
+		},
+	}
+
+	// curve25519-sha256@libssh.org is implemented but not listed in ssh.SupportedAlgorithms
+	keyExchangeCurve25519LibSSH = "curve25519-sha256@libssh.org"
+)
+
+func algorithmPreset(name string) (ssh.Algorithms, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return ssh.Algorithms{}, nil // golang.org/x/crypto/ssh defaults
+
+	case AlgorithmsModern:
+		return modernAlgorithms, nil
+
+	case AlgorithmsCompatible:
+		return ssh.SupportedAlgorithms(), nil
+
+	case AlgorithmsLegacy:
+		supported, insecure := ssh.SupportedAlgorithms(), ssh.InsecureAlgorithms()
+		return ssh.Algorithms{
+			Ciphers:      append(supported.Ciphers, insecure.Ciphers...),
+			KeyExchanges: append(supported.KeyExchanges, insecure.KeyExchanges...),
+			MACs:         append(supported.MACs, insecure.MACs...),
+			HostKeys:     append(supported.HostKeys, insecure.HostKeys...),
+		}, nil
+	}
+	return ssh.Algorithms{}, fmt.Errorf("unknown algorithm preset %q", name)
+}
+
+// algorithms returns the SSH algorithms to offer the remote server. Explicitly configured
+// algorithms override those from AlgorithmPreset. Empty lists fall back to the
+// golang.org/x/crypto/ssh defaults.
+func (cfg ClientConfig) algorithms() (ssh.Algorithms, error) {
+	algos, err := algorithmPreset(cfg.AlgorithmPreset)
+	if err != nil {
+		return algos, err
+	}
+	if len(cfg.Ciphers) > 0 {
+		algos.Ciphers = cfg.Ciphers
+	}
+	if len(cfg.KeyExchanges) > 0 {
+		algos.KeyExchanges = cfg.KeyExchanges
+	}
+	if len(cfg.MACs) > 0 {
+		algos.MACs = cfg.MACs
+	}
+	if len(cfg.HostKeyAlgorithms) > 0 {
+		algos.HostKeys = cfg.HostKeyAlgorithms
+	}
+
+	supported, insecure := ssh.SupportedAlgorithms(), ssh.InsecureAlgorithms()
+	if err := checkAlgorithms("cipher", algos.Ciphers, supported.Ciphers, insecure.Ciphers); err != nil {
+		return algos, err
+	}
+	if err := checkAlgorithms("key exchange", algos.KeyExchanges, supported.KeyExchanges, insecure.KeyExchanges, []string{keyExchangeCurve25519LibSSH}); err != nil {
+		return algos, err
+	}
+	if err := checkAlgorithms("MAC", algos.MACs, supported.MACs, insecure.MACs); err != nil {
+		return algos, err
+	}
+	if err := checkAlgorithms("host key algorithm", algos.HostKeys, supported.HostKeys, insecure.HostKeys); err != nil {
+		return algos, err
+	}
+	return algos, nil
+}
+
+// pinnedHostKeyAlgorithms returns the algorithms for pinned host keys which are also offered.
+// An empty offered list means the golang.org/x/crypto/ssh defaults are used.
+func pinnedHostKeyAlgorithms(pinned, offered []string) []string {
+	if len(offered) == 0 {
+		return pinned
+	}
+	var out []string
+	for _, algo := range pinned {
+		if slices.Contains(offered, algo) {
+			out = append(out, algo)
+		}
+	}
+	if len(out) == 0 {
+		return offered // let negotiation fail with the host key mismatch
+	}
+	return out
+}
+
+func checkAlgorithms(kind string, algos []string, known ...[]string) error {
+	for _, algo := range algos {
+		if !slices.ContainsFunc(known, func(names []string) bool {
+			return slices.Contains(names, algo)
+		}) {
+			return fmt.Errorf("unsupported %s %q", kind, algo)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/log"
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestClientConfig_InvalidAlgorithms(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  sftp.ClientConfig
+	}{
+		{
+			name: "unknown preset",
+			cfg:  sftp.ClientConfig{AlgorithmPreset: "paranoid"},
+		},
+		{
+			name: "unknown cipher",
+			cfg:  sftp.ClientConfig{Ciphers: []string{"rot13"}},
+		},
+		{
+			name: "unknown key exchange",
+			cfg:  sftp.ClientConfig{KeyExchanges: []string{"diffie-hellman-group0"}},
+		},
+		{
+			name: "unknown MAC",
+			cfg:  sftp.ClientConfig{MACs: []string{"hmac-md4"}},
+		},
+		{
+			name: "unknown host key algorithm",
+			cfg:  sftp.ClientConfig{HostKeyAlgorithms: []string{"ssh-foo"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Hostname = "localhost:invalid"
+
+			client, err := sftp.NewClient(log.NewTestLogger(), &tt.cfg)
+			require.ErrorContains(t, err, "invalid SSH algorithms")
+			require.Nil(t, client)
+		})
+	}
+}
+
+func TestClient_AlgorithmPresets(t *testing.T) {
+	// Setup a server which only offers an insecure cipher
+	server := sftptest.NewServer(t)
+	server.Config.Ciphers = []string{ssh.InsecureCipherAES128CBC}
+
+	tests := []struct {
+		name    string
+		preset  string
+		ciphers []string
+		wantErr bool
+	}{
+		{name: "defaults", preset: "", wantErr: true},
+		{name: "modern", preset: sftp.AlgorithmsModern, wantErr: true},
+		{name: "compatible", preset: sftp.AlgorithmsCompatible, wantErr: true},
+		{name: "legacy", preset: sftp.AlgorithmsLegacy, wantErr: false},
+		{name: "modern with cipher override", preset: sftp.AlgorithmsModern, ciphers: []string{ssh.InsecureCipherAES128CBC}, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+				Hostname:        server.Addr(),
+				Username:        sftptest.Username,
+				Password:        sftptest.Password,
+				Timeout:         5 * time.Second,
+				MaxConnections:  1,
+				AlgorithmPreset: tt.preset,
+				Ciphers:         tt.ciphers,
+			})
+			require.NotNil(t, client)
+			t.Cleanup(func() { client.Close() })
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, client.Ping())
+			}
+		})
+	}
+}
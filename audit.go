@@ -0,0 +1,231 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord is evidence of one mutating operation against a remote server.
+//
+// Records are hash-chained: Hash covers every other field including PrevHash,
+// which is the Hash of the record written before it.
+type AuditRecord struct {
+	Time     time.Time `json:"time"`
+	Hostname string    `json:"hostname"`
+	Username string    `json:"username"`
+	Op       string    `json:"op"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256,omitempty"`
+	Outcome  string    `json:"outcome"`
+	Error    string    `json:"error,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// auditedOps are the mutating operations which are written to the AuditLog.
+var auditedOps = map[string]bool{
+	"upload": true,
+	"delete": true,
+	"probe":  true, // ProbePermissions creates and removes files
+}
+
+func (r AuditRecord) computeHash() (string, error) {
+	r.Hash = ""
+	bs, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(bs)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditSink stores AuditRecords in the order they're written.
+type AuditSink interface {
+	// Append stores record after every record already in the sink.
+	Append(record AuditRecord) error
+
+	// LastHash returns the Hash of the newest record, or "" when the sink is empty.
+	LastHash() (string, error)
+}
+
+// AuditLog chains AuditRecords together and writes them to an AuditSink.
+// One AuditLog can be shared by multiple clients.
+type AuditLog struct {
+	sink AuditSink
+
+	mu   sync.Mutex
+	last string
+}
+
+// NewAuditLog continues the hash chain of records already in sink.
+func NewAuditLog(sink AuditSink) (*AuditLog, error) {
+	if sink == nil {
+		return nil, errors.New("nil AuditSink")
+	}
+	last, err := sink.LastHash()
+	if err != nil {
+		return nil, fmt.Errorf("reading last audit record: %w", err)
+	}
+	return &AuditLog{sink: sink, last: last}, nil
+}
+
+// Write links record to the previous record and appends it to the sink.
+func (l *AuditLog) Write(record AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.PrevHash = l.last
+	hash, err := record.computeHash()
+	if err != nil {
+		return fmt.Errorf("hashing audit record: %w", err)
+	}
+	record.Hash = hash
+
+	if err := l.sink.Append(record); err != nil {
+		return fmt.Errorf("writing audit record: %w", err)
+	}
+	l.last = hash
+	return nil
+}
+
+// AuditFile is an AuditSink which appends records as JSON lines to a local file.
+type AuditFile struct {
+	mu sync.Mutex
+	fd *os.File
+}
+
+// NewAuditFile opens or creates the file at path for appending audit records.
+func NewAuditFile(path string) (*AuditFile, error) {
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+	return &AuditFile{fd: fd}, nil
+}
+
+func (f *AuditFile) Append(record AuditRecord) error {
+	bs, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	bs = append(bs, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.fd.Write(bs); err != nil {
+		return err
+	}
+	return f.fd.Sync()
+}
+
+func (f *AuditFile) LastHash() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.fd.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	var last string
+	err := readAuditRecords(f.fd, func(_ int, record AuditRecord) error {
+		last = record.Hash
+		return nil
+	})
+	return last, err
+}
+
+func (f *AuditFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.fd.Close()
+}
+
+// VerifyAuditLog reads JSON-lines AuditRecords from r and checks their hash chain.
+// An error is returned for the first record which was modified, removed or reordered.
+func VerifyAuditLog(r io.Reader) error {
+	var prev string
+	return readAuditRecords(r, func(line int, record AuditRecord) error {
+		if record.PrevHash != prev {
+			return fmt.Errorf("audit record on line %d: previous hash %q does not match %q", line, record.PrevHash, prev)
+		}
+		hash, err := record.computeHash()
+		if err != nil {
+			return fmt.Errorf("audit record on line %d: %w", line, err)
+		}
+		if record.Hash != hash {
+			return fmt.Errorf("audit record on line %d: hash %q does not match contents", line, record.Hash)
+		}
+		prev = record.Hash
+		return nil
+	})
+}
+
+func readAuditRecords(r io.Reader, fn func(line int, record AuditRecord) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var line int
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("audit record on line %d: %w", line, err)
+		}
+		if err := fn(line, record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// audit records the outcome of a mutating operation.
+// Failing to write the record is logged rather than returned since the remote change has already happened.
+func (o *operation) audit(err error) {
+	c := o.client
+	if c.cfg.AuditLog == nil || !auditedOps[o.name] {
+		return
+	}
+
+	record := AuditRecord{
+		Time:     o.start.UTC(),
+		Hostname: c.cfg.Hostname,
+		Username: c.cfg.Username,
+		Op:       o.name,
+		Path:     o.path,
+		Size:     o.bytes,
+		SHA256:   o.sha256,
+		Outcome:  AuditSuccess,
+	}
+	if err != nil {
+		record.Outcome = AuditFailure
+		record.Error = err.Error()
+	}
+
+	if err := c.cfg.AuditLog.Write(record); err != nil && c.logger != nil {
+		c.logger.Error().Logf("sftp: %s of %s was not audited: %v", o.name, c.cfg.PathLogging.hide(o.path), err)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_AuditLog(t *testing.T) {
+	server := sftptest.NewServer(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	file, err := sftp.NewAuditFile(path)
+	require.NoError(t, err)
+	auditLog, err := sftp.NewAuditLog(file)
+	require.NoError(t, err)
+
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.AuditLog = auditLog
+	})
+
+	require.NoError(t, client.UploadFile("/outbox/file.txt", io.NopCloser(strings.NewReader("contents"))))
+	_, err = client.ListFiles("/outbox") // not audited
+	require.NoError(t, err)
+	server.FailOn("Remove", syscall.EACCES)
+	require.ErrorIs(t, client.Delete("/outbox/file.txt"), sftp.ErrPermissionDenied)
+	require.NoError(t, file.Close())
+
+	// Records continue the chain after reopening
+	file, err = sftp.NewAuditFile(path)
+	require.NoError(t, err)
+	auditLog, err = sftp.NewAuditLog(file)
+	require.NoError(t, err)
+	client = newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.AuditLog = auditLog
+	})
+	require.NoError(t, client.Delete("/outbox/file.txt"))
+	_, err = client.ProbePermissions("/outbox")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	records := readAuditFile(t, path)
+	require.Len(t, records, 4)
+
+	upload := records[0]
+	require.Equal(t, "upload", upload.Op)
+	require.Equal(t, "/outbox/file.txt", upload.Path)
+	require.Equal(t, server.Addr(), upload.Hostname)
+	require.Equal(t, sftptest.Username, upload.Username)
+	require.Equal(t, int64(8), upload.Size)
+	require.Equal(t, "d1b2a59fbea7e20077af9f91b27e95e865061b270be03ff539ab3b73587882e8", upload.SHA256)
+	require.Equal(t, sftp.AuditSuccess, upload.Outcome)
+	require.Empty(t, upload.PrevHash)
+
+	require.Equal(t, "delete", records[1].Op)
+	require.Equal(t, sftp.AuditFailure, records[1].Outcome)
+	require.NotEmpty(t, records[1].Error)
+	require.Equal(t, upload.Hash, records[1].PrevHash)
+
+	require.Equal(t, sftp.AuditSuccess, records[2].Outcome)
+	require.Equal(t, int64(8), records[2].Size)
+	require.Equal(t, records[1].Hash, records[2].PrevHash)
+
+	require.Equal(t, "probe", records[3].Op)
+	require.Equal(t, "/outbox", records[3].Path)
+	require.Equal(t, sftp.AuditSuccess, records[3].Outcome)
+	require.Equal(t, records[2].Hash, records[3].PrevHash)
+
+	bs, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, sftp.VerifyAuditLog(bytes.NewReader(bs)))
+
+	// Modified records are detected
+	tampered := bytes.Replace(bs, []byte(`"size":8`), []byte(`"size":9`), 1)
+	require.ErrorContains(t, sftp.VerifyAuditLog(bytes.NewReader(tampered)), "line 1: hash")
+
+	// Removed records are detected
+	lines := bytes.SplitAfter(bs, []byte("\n"))
+	removed := bytes.Join([][]byte{lines[0], lines[2]}, nil)
+	require.ErrorContains(t, sftp.VerifyAuditLog(bytes.NewReader(removed)), "line 2: previous hash")
+}
+
+func TestClient_AuditLogWriteFailure(t *testing.T) {
+	server := sftptest.NewServer(t)
+	auditLog, err := sftp.NewAuditLog(failingSink{})
+	require.NoError(t, err)
+
+	buf, logger := log.NewBufferLogger()
+	client, err := sftp.NewClient(logger, &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Password:       sftptest.Password,
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+		HostPublicKeys: []string{server.HostKey()},
+		AuditLog:       auditLog,
+		PathLogging:    sftp.PathLoggingRedact,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	// Failing to audit doesn't fail the upload or log the filename
+	require.NoError(t, client.UploadFile("/outbox/ssn-123-45-6789.txt", io.NopCloser(strings.NewReader("contents"))))
+	require.Contains(t, buf.String(), "was not audited")
+	require.NotContains(t, buf.String(), "ssn-123-45-6789")
+}
+
+type failingSink struct{}
+
+func (failingSink) Append(sftp.AuditRecord) error { return errors.New("disk full") }
+func (failingSink) LastHash() (string, error)     { return "", nil }
+
+func readAuditFile(t *testing.T, path string) []sftp.AuditRecord {
+	t.Helper()
+
+	fd, err := os.Open(path)
+	require.NoError(t, err)
+	defer fd.Close()
+
+	var records []sftp.AuditRecord
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		var record sftp.AuditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
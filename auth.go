@@ -0,0 +1,103 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH authentication methods which can be ordered with ClientConfig.AuthMethods
+const (
+	AuthPassword            = "password"
+	AuthKeyboardInteractive = "keyboard-interactive"
+	AuthPublicKey           = "publickey"
+)
+
+var defaultAuthMethods = []string{AuthPassword, AuthKeyboardInteractive, AuthPublicKey}
+
+// KeyboardInteractiveResponder answers a single keyboard-interactive prompt from the server,
+// such as a request for a one-time passcode. name and instruction are provided by the server
+// for the whole challenge and echo reports if the answer would be displayed while typed.
+type KeyboardInteractiveResponder func(name, instruction, question string, echo bool) (string, error)
+
+func checkAuthMethods(methods []string) error {
+	for _, method := range methods {
+		switch method {
+		case AuthPassword, AuthKeyboardInteractive, AuthPublicKey:
+		default:
+			return fmt.Errorf("unknown auth method %q", method)
+		}
+	}
+	return nil
+}
+
+// authMethods returns the ssh.AuthMethod for each configured method in the order they should be attempted.
+func authMethods(cfg ClientConfig) ([]ssh.AuthMethod, error) {
+	order := cfg.AuthMethods
+	if len(order) == 0 {
+		order = defaultAuthMethods
+	}
+
+	var out []ssh.AuthMethod
+	for _, method := range order {
+		switch method {
+		case AuthPassword:
+			if cfg.Password != "" {
+				out = append(out, ssh.Password(cfg.Password))
+			}
+
+		case AuthKeyboardInteractive:
+			if cfg.Password != "" || cfg.KeyboardInteractive != nil {
+				out = append(out, ssh.KeyboardInteractive(keyboardInteractiveChallenge(cfg.Password, cfg.KeyboardInteractive)))
+			}
+
+		case AuthPublicKey:
+			if cfg.ClientPrivateKey != "" {
+				signer, err := readSigner(cfg.ClientPrivateKey, cfg.ClientPrivateKeyPassword)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read client private key: %w", err)
+				}
+				out = append(out, ssh.PublicKeys(signer))
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown auth method %q", method)
+		}
+	}
+	return out, nil
+}
+
+// isAuthError returns true when the server rejected every authentication method offered.
+func isAuthError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ssh: unable to authenticate")
+}
+
+// keyboardInteractiveChallenge answers password prompts with password and delegates every other prompt to responder.
+// Other prompts are answered with an empty string when responder is nil.
+func keyboardInteractiveChallenge(password string, responder KeyboardInteractiveResponder) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i := range questions {
+			if password != "" && strings.Contains(strings.ToLower(questions[i]), "password") {
+				answers[i] = password
+				continue
+			}
+			if responder == nil {
+				// Leave the prompt unanswered so the server rejects this method and the next is tried,
+				// as returning an error would abort the handshake.
+				continue
+			}
+			answer, err := responder(name, instruction, questions[i], echos[i])
+			if err != nil {
+				return nil, fmt.Errorf("keyboard-interactive prompt %q: %w", questions[i], err)
+			}
+			answers[i] = answer
+		}
+		return answers, nil
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/log"
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestClient_KeyboardInteractive(t *testing.T) {
+	tests := []struct {
+		name      string
+		questions []string
+		responder sftp.KeyboardInteractiveResponder
+		wantErr   bool
+	}{
+		{
+			name:      "password prompt",
+			questions: []string{"Password: "},
+		},
+		{
+			name:      "password and one-time passcode",
+			questions: []string{"Password: ", "Verification code: "},
+			responder: func(name, instruction, question string, echo bool) (string, error) {
+				return "123456", nil
+			},
+		},
+		{
+			name:      "one-time passcode without responder",
+			questions: []string{"Password: ", "Verification code: "},
+			wantErr:   true,
+		},
+		{
+			name:      "responder error",
+			questions: []string{"Verification code: "},
+			responder: func(name, instruction, question string, echo bool) (string, error) {
+				return "", errors.New("no token available")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := sftptest.NewServer(t)
+			server.Config.PasswordCallback = nil
+			server.Config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+				answers, err := client("", "", tt.questions, make([]bool, len(tt.questions)))
+				if err != nil {
+					return nil, err
+				}
+				for i := range tt.questions {
+					want := sftptest.Password
+					if tt.questions[i] != "Password: " {
+						want = "123456"
+					}
+					if answers[i] != want {
+						return nil, errors.New("invalid answer")
+					}
+				}
+				return nil, nil
+			}
+
+			client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+				Hostname:            server.Addr(),
+				Username:            sftptest.Username,
+				Password:            sftptest.Password,
+				Timeout:             5 * time.Second,
+				MaxConnections:      1,
+				KeyboardInteractive: tt.responder,
+			})
+			require.NotNil(t, client)
+			t.Cleanup(func() { client.Close() })
+
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, client.Ping())
+			}
+		})
+	}
+}
+
+func TestClient_KeyboardInteractiveFallback(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var attempts []string
+
+	server := sftptest.NewServer(t)
+	server.Config.PasswordCallback = nil
+	server.Config.AuthLogCallback = func(conn ssh.ConnMetadata, method string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if method != "none" {
+			attempts = append(attempts, method)
+		}
+	}
+	server.Config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+		answers, err := client("", "", []string{"Verification code: "}, []bool{false})
+		if err != nil {
+			return nil, err
+		}
+		if answers[0] != "123456" {
+			return nil, errors.New("invalid answer")
+		}
+		return nil, nil
+	}
+	server.Config.PublicKeyCallback = func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+		if bytes.Equal(key.Marshal(), signer.PublicKey().Marshal()) {
+			return nil, nil
+		}
+		return nil, errors.New("unknown public key")
+	}
+
+	// Without a responder the passcode prompt is rejected and the private key is used
+	client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:         server.Addr(),
+		Username:         sftptest.Username,
+		Password:         sftptest.Password,
+		ClientPrivateKey: string(pem.EncodeToMemory(block)),
+		Timeout:          5 * time.Second,
+		MaxConnections:   1,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, client.Ping())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{sftp.AuthKeyboardInteractive, sftp.AuthPublicKey}, attempts)
+}
+
+func TestClient_AuthMethodOrder(t *testing.T) {
+	var mu sync.Mutex
+	var attempts []string
+
+	server := sftptest.NewServer(t)
+	server.Config.AuthLogCallback = func(conn ssh.ConnMetadata, method string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if method != "none" {
+			attempts = append(attempts, method)
+		}
+	}
+	server.Config.KeyboardInteractiveCallback = func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+		return nil, errors.New("keyboard-interactive rejected")
+	}
+
+	client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Password:       sftptest.Password,
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+		AuthMethods:    []string{sftp.AuthKeyboardInteractive, sftp.AuthPassword},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{sftp.AuthKeyboardInteractive, sftp.AuthPassword}, attempts)
+}
+
+func TestClientConfig_InvalidAuthMethods(t *testing.T) {
+	client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:    "localhost:invalid",
+		AuthMethods: []string{"gssapi-with-mic"},
+	})
+	require.ErrorContains(t, err, `unknown auth method "gssapi-with-mic"`)
+	require.Nil(t, client)
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/pkg/sftp"
+)
+
+// SFTP extensions which servers commonly advertise
+const (
+	ExtensionPosixRename = "posix-rename@openssh.com"
+	ExtensionStatVFS     = "statvfs@openssh.com"
+	ExtensionHardlink    = "hardlink@openssh.com"
+	ExtensionFsync       = "fsync@openssh.com"
+	ExtensionCopyData    = "copy-data"
+	ExtensionCheckFile   = "check-file"
+)
+
+// knownExtensions are reported in Capabilities.Extensions when advertised by the server.
+// github.com/pkg/sftp only allows checking for an extension by name.
+var knownExtensions = []string{
+	ExtensionPosixRename,
+	ExtensionStatVFS,
+	"fstatvfs@openssh.com",
+	ExtensionHardlink,
+	ExtensionFsync,
+	"lsetstat@openssh.com",
+	"limits@openssh.com",
+	"expand-path@openssh.com",
+	"users-groups-by-id@openssh.com",
+	ExtensionCopyData,
+	ExtensionCheckFile,
+	"home-directory",
+}
+
+// sftpProtocolVersion is the only version github.com/pkg/sftp negotiates.
+const sftpProtocolVersion = 3
+
+// Capabilities describes the SFTP protocol version and extensions offered by the server.
+type Capabilities struct {
+	ProtocolVersion int
+
+	// Extensions maps each advertised extension to its data, which is typically a version number.
+	Extensions map[string]string
+
+	PosixRename bool
+	StatVFS     bool
+	Hardlink    bool
+	Fsync       bool
+	CopyData    bool
+	CheckFile   bool
+}
+
+// Has returns true if the server advertised the named extension.
+func (caps Capabilities) Has(extension string) bool {
+	_, ok := caps.Extensions[extension]
+	return ok
+}
+
+func newCapabilities(conn *sftp.Client) *Capabilities {
+	caps := &Capabilities{
+		ProtocolVersion: sftpProtocolVersion,
+		Extensions:      make(map[string]string),
+	}
+	for _, name := range knownExtensions {
+		if data, ok := conn.HasExtension(name); ok {
+			caps.Extensions[name] = data
+		}
+	}
+	caps.PosixRename = caps.Has(ExtensionPosixRename)
+	caps.StatVFS = caps.Has(ExtensionStatVFS)
+	caps.Hardlink = caps.Has(ExtensionHardlink)
+	caps.Fsync = caps.Extensions[ExtensionFsync] == "1" // pkg/sftp only supports version 1
+	caps.CopyData = caps.Has(ExtensionCopyData)
+	caps.CheckFile = caps.Has(ExtensionCheckFile)
+	return caps
+}
+
+// Capabilities returns what the server supports, connecting if needed.
+// The result is cached until the connection is closed.
+func (c *client) Capabilities() (Capabilities, error) {
+	ctx, op := c.startOperation(context.Background(), "capabilities", "")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conn, err := c.connection(ctx)
+	err = c.clearConnectionOnError(ctx, err)
+	if err != nil {
+		return Capabilities{}, op.finish(err)
+	}
+	return *c.capabilities(conn), op.finish(nil)
+}
+
+// capabilities returns the cached Capabilities of conn.
+//
+// capabilities must be called within a mutex lock.
+func (c *client) capabilities(conn *sftp.Client) *Capabilities {
+	if c.caps == nil {
+		c.caps = newCapabilities(conn)
+	}
+	return c.caps
+}
+
+// DirPermissions reports which operations the server permits within a directory.
+type DirPermissions struct {
+	Mkdir bool
+	Chmod bool
+}
+
+// ProbePermissions tests whether directories can be created in dir and whether files in dir
+// can have their permissions changed, which UploadFile does unless SkipDirectoryCreation and
+// SkipChmodAfterUpload are set. Temporary files and directories are removed afterwards.
+//
+// Operations the server refuses are reported as not permitted, other errors are returned.
+func (c *client) ProbePermissions(dir string) (DirPermissions, error) {
+	ctx, op := c.startOperation(context.Background(), "probe", dir)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	perms, err := c.probePermissions(ctx, dir)
+	return perms, op.finish(err)
+}
+
+func (c *client) probePermissions(ctx context.Context, dir string) (DirPermissions, error) {
+	var perms DirPermissions
+
+	conn, err := c.connection(ctx)
+	err = c.clearConnectionOnError(ctx, err)
+	if err != nil {
+		return perms, err
+	}
+	probe := path.Join(dir, fmt.Sprintf(".probe.%s", rand.Text()[:8]))
+
+	// Create and remove a directory
+	err = conn.Mkdir(probe)
+	if err == nil {
+		perms.Mkdir = true
+		conn.RemoveDirectory(probe)
+	} else if err = c.refused(ctx, err); err != nil {
+		return perms, err
+	}
+
+	// Create a file and change its permissions
+	fd, err := conn.Create(probe)
+	if err != nil {
+		if err = c.refused(ctx, err); err != nil {
+			return perms, err
+		}
+		return perms, nil
+	}
+	defer conn.Remove(probe)
+
+	err = fd.Chmod(0600)
+	fd.Close()
+	if err == nil {
+		perms.Chmod = true
+	} else if err = c.refused(ctx, err); err != nil {
+		return perms, err
+	}
+
+	return perms, nil
+}
+
+// refused returns nil when err is the server refusing an operation rather than a connection problem.
+func (c *client) refused(ctx context.Context, err error) error {
+	if c.isConnectionLost(err) {
+		return c.clearConnectionOnError(ctx, err)
+	}
+	var status *sftp.StatusError
+	if errors.As(err, &status) || isPermissionDenied(err) || isUnsupported(err) {
+		return nil
+	}
+	return err
+}
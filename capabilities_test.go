@@ -0,0 +1,86 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Capabilities(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, nil)
+
+	caps, err := client.Capabilities()
+	require.NoError(t, err)
+	require.Equal(t, 3, caps.ProtocolVersion)
+	require.True(t, caps.PosixRename)
+	require.True(t, caps.StatVFS)
+	require.True(t, caps.Hardlink)
+	require.False(t, caps.Fsync)
+	require.False(t, caps.CopyData)
+	require.False(t, caps.CheckFile)
+	require.Equal(t, map[string]string{
+		sftp.ExtensionPosixRename: "1",
+		sftp.ExtensionStatVFS:     "2",
+		sftp.ExtensionHardlink:    "1",
+	}, caps.Extensions)
+	require.True(t, caps.Has(sftp.ExtensionHardlink))
+
+	// Uploads skip syncing since the server doesn't support fsync
+	require.NoError(t, client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	// Capabilities are discovered again after reconnecting
+	server.DropConnections()
+	caps, err = client.Capabilities()
+	require.NoError(t, err)
+	require.True(t, caps.PosixRename)
+	require.Equal(t, 2, server.Connections())
+}
+
+func TestClient_ProbePermissions(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, nil)
+	require.NoError(t, client.UploadFile("/outbox/file.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	perms, err := client.ProbePermissions("/outbox")
+	require.NoError(t, err)
+	require.True(t, perms.Mkdir)
+	require.True(t, perms.Chmod)
+
+	server.FailOn("Mkdir", syscall.EACCES)
+	server.FailOn("Setstat", syscall.EPERM)
+
+	perms, err = client.ProbePermissions("/outbox")
+	require.NoError(t, err)
+	require.False(t, perms.Mkdir)
+	require.False(t, perms.Chmod)
+
+	// The probe cleans up after itself
+	files, err := client.ListFiles("/outbox")
+	require.NoError(t, err)
+	require.Equal(t, []string{"/outbox/file.txt"}, files)
+}
+
+func TestMockClient_Capabilities(t *testing.T) {
+	client := sftp.NewMockClient(t)
+	client.Caps.PosixRename = true
+
+	caps, err := client.Capabilities()
+	require.NoError(t, err)
+	require.True(t, caps.PosixRename)
+
+	perms, err := client.ProbePermissions("/")
+	require.NoError(t, err)
+	require.True(t, perms.Mkdir)
+	require.True(t, perms.Chmod)
+}
@@ -6,36 +6,30 @@ package go_sftp
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/go-kit/kit/metrics/prometheus"
 	"github.com/moov-io/base/log"
 	"github.com/pkg/sftp"
-	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/ssh"
 )
 
-var (
-	sftpAgentUp = prometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
-		Name: "sftp_agent_up",
-		Help: "Status of SFTP agent connection",
-	}, []string{"hostname"})
-
-	sftpConnectionRetries = prometheus.NewCounterFrom(stdprometheus.CounterOpts{
-		Name: "sftp_connection_retries",
-		Help: "Counter of SFTP connection retry attempts",
-	}, []string{"hostname"})
-)
-
 type Client interface {
 	Ping() error
 	Close() error
@@ -50,6 +44,29 @@ type Client interface {
 	Walk(dir string, fn fs.WalkDirFunc) error
 }
 
+// ContextClient extends Client with context-aware methods and details about the server and client.
+// Clients returned by NewClient and NewMockClient implement ContextClient.
+type ContextClient interface {
+	Client
+
+	// Context-aware variants of the Client methods. The context is the parent of any
+	// tracing spans and is checked before connecting and while uploading contents.
+	PingContext(ctx context.Context) error
+	OpenContext(ctx context.Context, path string) (*File, error)
+	ReaderContext(ctx context.Context, path string) (*File, error)
+	DeleteContext(ctx context.Context, path string) error
+	UploadFileContext(ctx context.Context, path string, contents io.ReadCloser) error
+	ListFilesContext(ctx context.Context, dir string) ([]string, error)
+	WalkContext(ctx context.Context, dir string, fn fs.WalkDirFunc) error
+
+	ServerInfo() ServerInfo
+	Capabilities() (Capabilities, error)
+	ProbePermissions(dir string) (DirPermissions, error)
+	Stats() Stats
+}
+
+var _ ContextClient = (&client{})
+
 type client struct {
 	logger log.Logger
 	cfg    ClientConfig
@@ -57,57 +74,147 @@ type client struct {
 	mu     sync.Mutex // protects all read/write methods
 	conn   *ssh.Client
 	client *sftp.Client
+	active atomic.Pointer[ssh.Client] // conn, which Close cuts off without waiting for mu
+
+	keepalive atomic.Pointer[keepalive]
+
+	idleTimer   *time.Timer
+	lastUsed    atomic.Int64 // unix nanoseconds
+	openReaders atomic.Int32
+
+	connectedAt     time.Time
+	disconnectCause error // why the last connection was closed, until reconnecting
+
+	metrics    *metrics
+	stats      statsRecorder
+	tracer     trace.Tracer
+	serverInfo atomic.Pointer[ServerInfo]
+	caps       *Capabilities // cached for the current connection
+	pgp        *pgpCodec
 }
 
 func NewClient(logger log.Logger, cfg *ClientConfig) (Client, error) {
 	if cfg == nil {
 		return nil, errors.New("nil SFTP config")
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, newError("connect", "", fmt.Errorf("sftp: %w", err))
+	}
+
+	metrics, err := newMetrics(*cfg)
+	if err != nil {
+		return nil, newError("connect", "", fmt.Errorf("sftp: %w", err))
+	}
+
+	pgp, err := newPGPCodec(cfg.PGP)
+	if err != nil {
+		return nil, newError("connect", "", fmt.Errorf("sftp: %w", err))
+	}
+
+	cc := &client{cfg: *cfg, logger: logger, metrics: metrics, tracer: newTracer(*cfg), pgp: pgp}
+	cc.setupIdleTimer()
 
-	cc := &client{cfg: *cfg, logger: logger}
+	if cfg.LazyConnect {
+		if cfg.LazyConnectWarmup {
+			go cc.warmup()
+		}
+		return cc, nil
+	}
 
-	conn, err := cc.connection()
+	ctx := context.Background()
+	conn, err := cc.connection(ctx)
 	cc.record(err) // track up metric for remote server
-	err = cc.clearConnectionOnError(err)
+	err = cc.clearConnectionOnError(ctx, err)
 
 	// Print an initial startup message
 	if conn != nil && logger != nil {
 		wd, wdErr := conn.Getwd()
 		if wdErr != nil {
-			err = cc.clearConnectionOnError(wdErr)
+			err = cc.clearConnectionOnError(ctx, wdErr)
 		}
 		if wd != "" {
 			logger.Logf("starting SFTP client in %s", wd)
 		}
 	}
 
-	return cc, err
+	return cc, newError("connect", "", err)
+}
+
+// warmup connects to the remote server in the background for clients using LazyConnect.
+func (c *client) warmup() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx := context.Background()
+	conn, err := c.connection(ctx)
+	c.record(err)
+	if c.logger == nil {
+		return
+	}
+	if err != nil {
+		c.logger.Warn().Logf("sftp: warming up connection to %s: %v", c.cfg.Hostname, err)
+		return
+	}
+	if wd, _ := conn.Getwd(); wd != "" {
+		c.logger.Logf("starting SFTP client in %s", wd)
+	}
 }
 
 // connection returns an sftp.Client which is connected to the remote server.
 // This function will attempt to establish a new connection if none exists already.
 //
 // connection must be called within a mutex lock.
-func (c *client) connection() (*sftp.Client, error) {
+func (c *client) connection(ctx context.Context) (*sftp.Client, error) {
 	if c == nil {
 		return nil, errors.New("nil client / config")
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.markUsed()
 
 	if c.client != nil {
+		// Keepalives are verifying the connection in the background
+		if c.keepaliveHealthy() {
+			return c.client, nil
+		}
 		// Verify the connection works and if not drop through and reconnect
 		if _, err := c.client.Getwd(); err == nil {
 			return c.client, nil
 		} else {
 			// Our connection is having issues, so retry connecting
-			c.client.Close()
+			c.teardown(err)
 		}
 	}
 
-	conn, stdin, stdout, err := sftpConnect(c.logger, c.cfg)
+	spanName := "connect"
+	if c.disconnectCause != nil {
+		spanName = "reconnect"
+	}
+	_, span := c.startSpan(ctx, spanName)
+
+	client, err := c.connect(span)
+	endSpan(span, err)
+
+	return client, err
+}
+
+// connect establishes new SSH and SFTP connections.
+//
+// connect must be called within a mutex lock.
+func (c *client) connect(span trace.Span) (*sftp.Client, error) {
+	start := time.Now()
+	var info connectInfo
+	conn, stdin, stdout, err := sftpConnect(c.logger, c.cfg, &info)
+	span.SetAttributes(attribute.Int("sftp.attempts", info.attempts))
+	if info.attempts > 1 {
+		c.metrics.connectionRetried(info.attempts - 1)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("sftp: %w", err)
 	}
 	c.conn = conn
+	c.active.Store(conn)
 
 	// Setup our SFTP client
 	var opts = []sftp.ClientOption{
@@ -127,42 +234,109 @@ func (c *client) connection() (*sftp.Client, error) {
 	}
 	c.client = client
 
+	c.startKeepalive(conn)
+
+	if err := c.connected(conn, client, info, time.Since(start)); err != nil {
+		c.teardown(err)
+		return nil, err
+	}
+
 	return c.client, nil
 }
 
+// teardown closes the SSH and SFTP connections. cause is the error which made
+// the connection unusable, or nil when it's closed deliberately.
+//
+// teardown must be called within a mutex lock.
+func (c *client) teardown(cause error) {
+	c.keepalive.Swap(nil).close()
+
+	c.caps = nil
+
+	if c.conn == nil && c.client == nil {
+		return
+	}
+	defer c.disconnected(cause)
+
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+		c.active.Store(nil)
+	}
+	if c.client != nil {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
 // clearConnectionOnError accepts any error from a call involving the SSH/SFTP connection.
 // If an error is encountered that causes either connection (SSH or SFTP) to be
 // lost it will tear down the connections. The next invocation of c.connection()
 // will re-establish new connections.
 //
-// When the error is captured by clearConnectionOnError the client will attempt to reconnect
-// and that new connection error will be returned.
-func (c *client) clearConnectionOnError(err error) error {
+// When the error is captured by clearConnectionOnError the client will attempt to reconnect.
+// If reconnecting fails that new connection error will be returned, otherwise the original
+// error is returned so the operation can be retried.
+func (c *client) clearConnectionOnError(ctx context.Context, err error) error {
 	if err == nil {
 		return nil
 	}
-	// Possible errors from github.com/pkg/sftp/request-errors.go
-	switch {
-	case errors.Is(err, sftp.ErrSSHFxEOF),
-		errors.Is(err, sftp.ErrSSHFxFailure),
-		errors.Is(err, sftp.ErrSSHFxBadMessage),
-		errors.Is(err, sftp.ErrSSHFxNoConnection),
-		errors.Is(err, sftp.ErrSSHFxConnectionLost):
+	if c.isConnectionLost(err) {
 		// Teardown the existing connections
-		if c.conn != nil {
-			c.conn.Close()
-			c.conn = nil
+		c.teardown(err)
+		// Reconnect if needed and replace the initial error if that fails
+		if _, connErr := c.connection(ctx); connErr != nil {
+			return connErr
 		}
-		if c.client != nil {
-			c.client.Close()
-			c.client = nil
+	}
+	return err
+}
+
+// retryOperation calls fn and retries it according to OperationRetryPolicy
+// when the connection was lost and has been re-established.
+func (c *client) retryOperation(ctx context.Context, fn func() error) error {
+	op := operationFrom(ctx)
+	attempt := func() error {
+		if op != nil {
+			op.attempts++
 		}
-		// Reconnect if needed and replace the initial error
-		_, err = c.connection()
+		return fn()
+	}
+	if c.cfg.OperationRetryPolicy == nil {
+		return attempt()
+	}
+	policy := *c.cfg.OperationRetryPolicy
+	if policy.Retryable == nil {
+		policy.Retryable = c.isConnectionLost
+	}
+	return policy.retry(attempt, nil)
+}
+
+// step runs fn within a span named after the step, such as "stat" or "chmod".
+func (c *client) step(ctx context.Context, name, path string, fn func() error) error {
+	_, span := c.startSpan(ctx, name, attribute.String("sftp.path", path))
+	err := fn()
+	if isNotFound(err) {
+		endSpan(span, nil) // callers expect missing files
+	} else {
+		endSpan(span, err)
 	}
 	return err
 }
 
+// contextReader stops reading once ctx is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
 var (
 	hostKeyCallbackOnce sync.Once
 	hostKeyCallback     = func(logger log.Logger) {
@@ -173,19 +347,36 @@ var (
 	}
 )
 
-func sftpConnect(logger log.Logger, cfg ClientConfig) (*ssh.Client, io.WriteCloser, io.Reader, error) {
+func sftpConnect(logger log.Logger, cfg ClientConfig, info *connectInfo) (*ssh.Client, io.WriteCloser, io.Reader, error) {
 	conf := &ssh.ClientConfig{
 		User:    cfg.Username,
 		Timeout: cfg.Timeout,
+		BannerCallback: func(message string) error {
+			info.banner = message
+			return nil
+		},
 	}
+	algos, err := cfg.algorithms()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("sftpConnect: %w", err)
+	}
+	conf.Ciphers = algos.Ciphers
+	conf.KeyExchanges = algos.KeyExchanges
+	conf.MACs = algos.MACs
+	conf.HostKeyAlgorithms = algos.HostKeys
 	conf.SetDefaults()
 
 	if hostKeys := cfg.HostKeys(); len(hostKeys) > 0 {
-		callback, err := NewMultiKeyCallback(hostKeys)
+		callback, err := newMultiKeyCallback(hostKeys)
 		if err != nil {
 			return nil, nil, nil, err
 		}
-		conf.HostKeyCallback = callback
+		conf.HostKeyCallback = callback.check
+
+		// Only accept host key algorithms which match a configured key, unless they were explicitly set
+		if len(cfg.HostKeyAlgorithms) == 0 {
+			conf.HostKeyAlgorithms = pinnedHostKeyAlgorithms(callback.hostKeyAlgorithms(), algos.HostKeys)
+		}
 	} else {
 		hostKeyCallbackOnce.Do(func() {
 			hostKeyCallback(logger)
@@ -194,33 +385,53 @@ func sftpConnect(logger log.Logger, cfg ClientConfig) (*ssh.Client, io.WriteClos
 		conf.HostKeyCallback = ssh.InsecureIgnoreHostKey() // insecure default
 	}
 	// Setup various Authentication methods
-	if cfg.Password != "" {
-		conf.Auth = append(conf.Auth, ssh.Password(cfg.Password))
-	}
-	if cfg.ClientPrivateKey != "" {
-		signer, err := readSigner(cfg.ClientPrivateKey, cfg.ClientPrivateKeyPassword)
+	setupAuth := func() error {
+		creds, err := cfg.withCredentials()
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("sftpConnect: failed to read client private key: %w", err)
+			return err
 		}
-		conf.Auth = append(conf.Auth, ssh.PublicKeys(signer))
+		conf.Auth, err = authMethods(creds)
+		return err
+	}
+	if err := setupAuth(); err != nil {
+		return nil, nil, nil, fmt.Errorf("sftpConnect: %w", err)
 	}
 
 	// Connect to the remote server
+	policy := DefaultRetryPolicy
+	if cfg.ConnectRetryPolicy != nil {
+		policy = *cfg.ConnectRetryPolicy
+	}
+	if cfg.Credentials != nil {
+		// Credentials could have been rotated, so retry auth failures with reloaded credentials
+		retryable := policy.Retryable
+		if retryable == nil {
+			retryable = DefaultRetryable
+		}
+		policy.Retryable = func(err error) bool {
+			return isAuthError(err) || retryable(err)
+		}
+	}
+
 	var client *ssh.Client
-	var err error
-	for i := 0; i < 3; i++ {
-		if client == nil {
-			if i > 0 {
-				sftpConnectionRetries.With("hostname", cfg.Hostname).Add(1)
+	err = policy.retry(func() error {
+		info.attempts++
+		info.banner = ""
+
+		var err error
+		client, err = ssh.Dial("tcp", cfg.Hostname, conf)
+		return err
+	}, func(err error) {
+		if isAuthError(err) && cfg.Credentials != nil {
+			if authErr := setupAuth(); authErr != nil && logger != nil {
+				logger.Warn().Logf("sftpConnect: reloading credentials: %v", authErr)
 			}
-			client, err = ssh.Dial("tcp", cfg.Hostname, conf) // retry connection
-			time.Sleep(250 * time.Millisecond)
 		}
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("sftpConnect: %w", err)
 	}
 	if client == nil {
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("sftpConnect: %w", err)
-		}
 		return nil, nil, nil, fmt.Errorf("sftpConnect: unable to establish ssh connection")
 	}
 
@@ -271,77 +482,107 @@ func readPrivateKey(data []byte, passphrase string) (ssh.Signer, error) {
 }
 
 func (c *client) Ping() error {
+	return c.PingContext(context.Background())
+}
+
+func (c *client) PingContext(ctx context.Context) error {
 	if c == nil {
 		return errors.New("nil SFTPTransferAgent")
 	}
+	ctx, op := c.startOperation(ctx, "ping", "")
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	conn, err := c.connection()
+	conn, err := c.connection(ctx)
 	c.record(err)
-	err = c.clearConnectionOnError(err)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
-		return err
+		return op.finish(err)
 	}
 
 	_, err = conn.ReadDir(".")
 	c.record(err)
-	err = c.clearConnectionOnError(err)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
-		return fmt.Errorf("sftp: ping %w", err)
+		return op.finish(fmt.Errorf("sftp: ping %w", err))
 	}
-	return nil
+	return op.finish(nil)
 }
 
 func (c *client) record(err error) {
 	if c == nil {
 		return
 	}
-	if err != nil {
-		sftpAgentUp.With("hostname", c.cfg.Hostname).Set(0)
-	} else {
-		sftpAgentUp.With("hostname", c.cfg.Hostname).Set(1)
-	}
+	c.metrics.setUp(err == nil)
 }
 
 func (c *client) Close() error {
 	if c == nil {
 		return nil
 	}
-	if c.client != nil {
-		c.client.Close()
+	// Close the connection first so an operation stuck on a dead connection releases the lock
+	if conn := c.active.Load(); conn != nil {
+		conn.Close()
 	}
-	if c.conn != nil {
-		c.conn.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
 	}
+	c.teardown(nil)
+
 	return nil
 }
 
 func (c *client) Delete(path string) error {
+	return c.DeleteContext(context.Background(), path)
+}
+
+func (c *client) DeleteContext(ctx context.Context, path string) error {
+	ctx, op := c.startOperation(ctx, "delete", path)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	conn, err := c.connection()
-	err = c.clearConnectionOnError(err)
+	err := c.retryOperation(ctx, func() error {
+		return c.delete(ctx, path)
+	})
+	return op.finish(err)
+}
+
+func (c *client) delete(ctx context.Context, path string) error {
+	conn, err := c.connection(ctx)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
 		return err
 	}
 
-	info, err := conn.Stat(path)
+	var info fs.FileInfo
+	err = c.step(ctx, "stat", path, func() (err error) {
+		info, err = conn.Stat(path)
+		return err
+	})
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // file doesn't exist
 		}
 
 		// The error is something else related to STAT so return that
-		err = c.clearConnectionOnError(err)
+		err = c.clearConnectionOnError(ctx, err)
 		return fmt.Errorf("sftp: delete stat: %w", err)
 	}
 
 	if info != nil {
-		err := conn.Remove(path)
-		err = c.clearConnectionOnError(err)
+		if op := operationFrom(ctx); op != nil {
+			op.bytes = info.Size()
+		}
+		err := c.step(ctx, "remove", path, func() error {
+			return conn.Remove(path)
+		})
+		err = c.clearConnectionOnError(ctx, err)
 		if err != nil {
 			return fmt.Errorf("sftp: delete: %w", err)
 		}
@@ -352,15 +593,65 @@ func (c *client) Delete(path string) error {
 
 // UploadFile creates a file containing the provided contents at the specified path
 //
-// The File's contents will always be closed
+// The File's contents will always be closed.
+// Uploads are retried with OperationRetryPolicy only when AtomicUploads is enabled
+// and contents implements io.Seeker (such as *os.File) so it can be read again.
 func (c *client) UploadFile(path string, contents io.ReadCloser) error {
+	return c.UploadFileContext(context.Background(), path, contents)
+}
+
+func (c *client) UploadFileContext(ctx context.Context, path string, contents io.ReadCloser) error {
 	defer contents.Close()
 
+	ctx, op := c.startOperation(ctx, "upload", path)
+
+	// Buffered validators reject files before connecting
+	validated, err := validateBuffered(c.cfg.Validators, path, contents)
+	if err != nil {
+		return op.finish(err)
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	conn, err := c.connection()
-	err = c.clearConnectionOnError(err)
+	return op.finish(c.upload(ctx, path, validated))
+}
+
+func (c *client) upload(ctx context.Context, path string, contents io.Reader) error {
+	if !c.cfg.AtomicUploads {
+		// Stream validators need a temporary file to remove if validation fails
+		if hasStreamValidators(c.cfg.Validators, path) {
+			return c.uploadFile(ctx, path, tempUploadPath(path), contents)
+		}
+		return c.uploadFile(ctx, path, path, contents)
+	}
+
+	// Write to a temporary file which is renamed once complete
+	seeker, ok := contents.(io.Seeker)
+	if !ok {
+		return c.uploadFile(ctx, path, tempUploadPath(path), contents)
+	}
+
+	var previous string
+	return c.retryOperation(ctx, func() error {
+		if previous != "" {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("sftp: rewinding contents of %s: %w", path, err)
+			}
+			// Cleanup the temporary file from the failed attempt
+			if conn, err := c.connection(ctx); err == nil {
+				conn.Remove(previous)
+			}
+		}
+		previous = tempUploadPath(path)
+		return c.uploadFile(ctx, path, previous, contents)
+	})
+}
+
+// uploadFile writes contents to target, which is renamed to path if they differ.
+func (c *client) uploadFile(ctx context.Context, path, target string, contents io.Reader) (err error) {
+	conn, err := c.connection(ctx)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
 		return err
 	}
@@ -369,12 +660,18 @@ func (c *client) UploadFile(path string, contents io.ReadCloser) error {
 	if !c.cfg.SkipDirectoryCreation {
 		dir, _ := filepath.Split(path)
 
-		info, err := conn.Stat(dir)
-		err = c.clearConnectionOnError(err)
+		var info fs.FileInfo
+		err := c.step(ctx, "stat", dir, func() (err error) {
+			info, err = conn.Stat(dir)
+			return err
+		})
+		err = c.clearConnectionOnError(ctx, err)
 		if info == nil || err != nil {
-			if os.IsNotExist(err) || strings.Contains(err.Error(), "file does not exist") {
-				err := conn.MkdirAll(dir)
-				err = c.clearConnectionOnError(err)
+			if isNotFound(err) {
+				err := c.step(ctx, "mkdir", dir, func() error {
+					return conn.MkdirAll(dir)
+				})
+				err = c.clearConnectionOnError(ctx, err)
 				if err != nil {
 					return fmt.Errorf("sftp: problem creating %s as parent dir: %w", dir, err)
 				}
@@ -384,63 +681,229 @@ func (c *client) UploadFile(path string, contents io.ReadCloser) error {
 		}
 	}
 
+	if target != path {
+		defer func() {
+			if err != nil {
+				conn.Remove(target)
+			}
+		}()
+	}
+
+	_, span := c.startSpan(ctx, "write", attribute.String("sftp.path", target))
+
 	// Some servers don't allow you to open a file for reading and writing at the same time.
 	// For these we follow the pkg/sftp docs to open files for writing (not reading).
-	fd, err := conn.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
-	err = c.clearConnectionOnError(err)
+	fd, err := conn.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
+		endSpan(span, err)
 		return fmt.Errorf("sftp: problem creating remote file %s: %w", path, err)
 	}
 	if fd == nil {
+		endSpan(span, nil)
 		return fmt.Errorf("sftp: nil fd opening: %s", path)
 	}
 
-	n, err := io.Copy(fd, contents)
+	var w io.Writer = fd
+	var digest hash.Hash
+	if c.cfg.AuditLog != nil {
+		digest = sha256.New()
+		w = io.MultiWriter(fd, digest)
+	}
+	validating, finishValidation := validateStream(c.cfg.Validators, path, contextReader{ctx: ctx, r: contents})
+	defer finishValidation(io.ErrClosedPipe)
+
+	stages := applyTransforms(validating, c.cfg.UploadTransforms)
+	defer stages.Close()
+
+	var src io.Reader = stages
+	if c.cfg.Compression.forPath(path) == CompressionGzip {
+		compressed := c.cfg.Compression.compress(src)
+		defer compressed.Close()
+		src = compressed
+	}
+	if c.pgp.encrypts() {
+		encrypted := c.pgp.encrypt(src)
+		defer encrypted.Close()
+		src = encrypted
+	}
+	var signature *signatureWriter
+	if c.pgp.signsDetached() {
+		signature = c.pgp.signDetached()
+		defer signature.finish(io.ErrClosedPipe) // stops signing when the upload fails
+		src = io.TeeReader(src, signature)
+	}
+
+	n, err := io.Copy(w, sourceReader{r: src})
+	c.metrics.uploaded(n)
+	c.stats.uploaded(n)
+	if op := operationFrom(ctx); op != nil {
+		op.bytes = n
+		if digest != nil {
+			op.sha256 = hex.EncodeToString(digest.Sum(nil))
+		}
+	}
+	span.SetAttributes(attribute.Int64("sftp.bytes", n))
+	endSpan(span, err)
 	if err != nil {
+		fd.Close()
+		err = c.clearConnectionOnError(ctx, err)
 		return fmt.Errorf("sftp: problem copying (n=%d) %s: %w", n, path, err)
 	}
+	if err := stages.Close(); err != nil {
+		fd.Close()
+		return fmt.Errorf("sftp: closing upload transforms for %s: %w", path, err)
+	}
+	if err := finishValidation(nil); err != nil {
+		fd.Close()
+		return err
+	}
+	var sig []byte
+	if signature != nil {
+		sig, err = signature.finish(nil)
+		if err != nil {
+			fd.Close()
+			return fmt.Errorf("sftp: signing %s: %w", path, err)
+		}
+	}
 
-	if !c.cfg.SkipSyncAfterUpload {
-		err = fd.Sync()
-		err = c.clearConnectionOnError(err)
+	// Skip sync if the remote server doesn't support it
+	caps := c.capabilities(conn)
+	if !c.cfg.SkipSyncAfterUpload && caps.Fsync {
+		err = c.step(ctx, "sync", target, fd.Sync)
+		err = c.clearConnectionOnError(ctx, err)
 		if err != nil {
-			// Skip sync if the remote server doesn't support it
-			if !strings.Contains(err.Error(), "SSH_FX_OP_UNSUPPORTED") {
-				return fmt.Errorf("sftp: problem with sync on %s: %v", path, err)
-			}
+			fd.Close()
+			return fmt.Errorf("sftp: problem with sync on %s: %v", path, err)
 		}
 	}
 
 	if !c.cfg.SkipChmodAfterUpload {
-		err := fd.Chmod(0600)
-		err = c.clearConnectionOnError(err)
+		err := c.step(ctx, "chmod", target, func() error {
+			return fd.Chmod(0600)
+		})
+		err = c.clearConnectionOnError(ctx, err)
 		if err != nil {
+			fd.Close()
 			return fmt.Errorf("sftp: problem chmod %s: %w", path, err)
 		}
 	}
 
 	err = fd.Close()
-	err = c.clearConnectionOnError(err)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
 		return fmt.Errorf("sftp: closing %s after writing failed: %w", path, err)
 	}
 
+	// Upload the detached signature before the file is renamed into place
+	if sig != nil {
+		sigPath := c.pgp.signaturePath(path)
+		err = c.step(ctx, "signature", sigPath, func() error {
+			return writeRemoteFile(conn, sigPath, sig, !c.cfg.SkipChmodAfterUpload)
+		})
+		err = c.clearConnectionOnError(ctx, err)
+		if err != nil {
+			return fmt.Errorf("sftp: uploading signature of %s: %w", path, err)
+		}
+	}
+
+	if target != path {
+		err = c.step(ctx, "rename", path, func() error {
+			return renameFile(conn, caps.PosixRename, target, path)
+		})
+		err = c.clearConnectionOnError(ctx, err)
+		if err != nil {
+			return fmt.Errorf("sftp: renaming %s into place: %w", path, err)
+		}
+	}
+
 	return nil
 }
 
+// tempUploadPath returns a hidden file next to path for writing contents before renaming into place.
+func tempUploadPath(path string) string {
+	dir, filename := filepath.Split(path)
+	return dir + fmt.Sprintf(".%s.%s.tmp", filename, rand.Text()[:8])
+}
+
+// writeRemoteFile creates or replaces path with contents which are already in memory.
+func writeRemoteFile(conn *sftp.Client, path string, contents []byte, chmod bool) error {
+	fd, err := conn.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := fd.Write(contents); err != nil {
+		fd.Close()
+		return err
+	}
+	if chmod {
+		if err := fd.Chmod(0600); err != nil {
+			fd.Close()
+			return err
+		}
+	}
+	return fd.Close()
+}
+
+// readRemoteFile returns the contents of path, which must be no larger than limit.
+func readRemoteFile(conn *sftp.Client, path string, limit int64) ([]byte, error) {
+	fd, err := conn.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	bs, err := io.ReadAll(io.LimitReader(fd, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(bs)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", path, limit)
+	}
+	return bs, nil
+}
+
+// renameFile moves oldpath to newpath and replaces any existing file at newpath.
+func renameFile(conn *sftp.Client, posixRename bool, oldpath, newpath string) error {
+	if posixRename {
+		return conn.PosixRename(oldpath, newpath)
+	}
+	// SFTP v3 renames fail when newpath exists
+	if err := conn.Remove(newpath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return conn.Rename(oldpath, newpath)
+}
+
 // ListFiles will return the paths of files within dir. Paths are returned as locations from dir,
 // so if dir is an absolute path the returned paths will be.
 //
 // Paths are matched in case-insensitive comparisons, but results are returned exactly as they
 // appear on the server.
 func (c *client) ListFiles(dir string) ([]string, error) {
+	return c.ListFilesContext(context.Background(), dir)
+}
+
+func (c *client) ListFilesContext(ctx context.Context, dir string) ([]string, error) {
+	ctx, op := c.startOperation(ctx, "list", dir)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	var filenames []string
+	err := c.retryOperation(ctx, func() error {
+		var err error
+		filenames, err = c.listFiles(ctx, dir)
+		return err
+	})
+	return filenames, op.finish(err)
+}
+
+func (c *client) listFiles(ctx context.Context, dir string) ([]string, error) {
 	pattern := filepath.Clean(strings.TrimPrefix(dir, string(os.PathSeparator)))
 
-	conn, err := c.connection()
-	if err = c.clearConnectionOnError(err); err != nil {
+	conn, err := c.connection(ctx)
+	if err = c.clearConnectionOnError(ctx, err); err != nil {
 		return nil, err
 	}
 
@@ -457,13 +920,13 @@ func (c *client) ListFiles(dir string) ([]string, error) {
 	case pattern != "":
 		pattern = "[/?]" + pattern + "/*"
 		wd, err = conn.Getwd()
-		if err = c.clearConnectionOnError(err); err != nil {
+		if err = c.clearConnectionOnError(ctx, err); err != nil {
 			return nil, err
 		}
 	}
 
 	var filenames []string
-	err = c.walkNoLock(wd, func(path string, d fs.DirEntry, err error) error {
+	err = c.walkNoLock(ctx, wd, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -502,17 +965,33 @@ func (c *client) ListFiles(dir string) ([]string, error) {
 // Callers should be aware that network errors while reading can occur since contents
 // are streamed from the SFTP server.
 func (c *client) Reader(path string) (*File, error) {
+	return c.ReaderContext(context.Background(), path)
+}
+
+func (c *client) ReaderContext(ctx context.Context, path string) (*File, error) {
+	ctx, op := c.startOperation(ctx, "read", path)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	conn, err := c.connection()
-	err = c.clearConnectionOnError(err)
+	var file *File
+	err := c.retryOperation(ctx, func() error {
+		var err error
+		file, err = c.reader(ctx, path)
+		return err
+	})
+	return file, op.finish(err)
+}
+
+func (c *client) reader(ctx context.Context, path string) (*File, error) {
+	conn, err := c.connection(ctx)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
 		return nil, err
 	}
 
 	fd, err := conn.Open(path)
-	err = c.clearConnectionOnError(err)
+	err = c.clearConnectionOnError(ctx, err)
 	if err != nil {
 		return nil, fmt.Errorf("sftp: open %s: %w", path, err)
 	}
@@ -527,9 +1006,43 @@ func (c *client) Reader(path string) (*File, error) {
 		modTime = stat.ModTime()
 	}
 
+	raw := &rawReader{ReadCloser: c.stats.countDownload(c.metrics.countDownload(c.trackReader(fd)))}
+	var contents io.ReadCloser = raw
+	if c.pgp.verifiesDetached() {
+		sigPath := c.pgp.signaturePath(path)
+		var sig []byte
+		err := c.step(ctx, "signature", sigPath, func() (err error) {
+			sig, err = readRemoteFile(conn, sigPath, maxSignatureSize)
+			return err
+		})
+		err = c.clearConnectionOnError(ctx, err)
+		if err != nil {
+			contents.Close()
+			return nil, fmt.Errorf("sftp: reading signature %s: %w", sigPath, err)
+		}
+		contents = c.pgp.checkDetached(contents, sig)
+	}
+	if c.pgp.decrypts() {
+		decrypted, err := c.pgp.decrypt(contents)
+		if err != nil {
+			contents.Close()
+			return nil, fmt.Errorf("sftp: reading %s: %w", path, raw.decoded(err))
+		}
+		contents = decodingReader{ReadCloser: decrypted, raw: raw}
+	}
+	if c.cfg.Compression.forPath(path) == CompressionGzip {
+		decompressed, err := c.cfg.Compression.decompress(contents)
+		if err != nil {
+			contents.Close()
+			return nil, fmt.Errorf("sftp: reading %s: %w", path, raw.decoded(err))
+		}
+		contents = decodingReader{ReadCloser: decompressed, raw: raw}
+	}
+	contents = transformDownload(contents, c.cfg.DownloadTransforms)
+
 	return &File{
 		Filename: fd.Name(),
-		Contents: fd,
+		Contents: contents,
 		ModTime:  modTime,
 		fileinfo: fileinfo,
 	}, nil
@@ -538,14 +1051,36 @@ func (c *client) Reader(path string) (*File, error) {
 // Open will return the contents at path and consume the entire file contents.
 // WARNING: This method can use a lot of memory by consuming the entire file into memory.
 func (c *client) Open(path string) (*File, error) {
-	r, err := c.Reader(path)
+	return c.OpenContext(context.Background(), path)
+}
+
+func (c *client) OpenContext(ctx context.Context, path string) (*File, error) {
+	ctx, op := c.startOperation(ctx, "open", path)
+
+	var file *File
+	err := c.retryOperation(ctx, func() error {
+		var err error
+		file, err = c.open(ctx, path)
+		return err
+	})
+	return file, op.finish(err)
+}
+
+func (c *client) open(ctx context.Context, path string) (*File, error) {
+	c.mu.Lock()
+	r, err := c.reader(ctx, path)
+	c.mu.Unlock()
 	if err != nil {
 		return nil, err
 	}
 
 	// read the entire remote file
 	var buf bytes.Buffer
-	if n, err := io.Copy(&buf, r.Contents); err != nil {
+	n, err := io.Copy(&buf, r.Contents)
+	if op := operationFrom(ctx); op != nil {
+		op.bytes = n
+	}
+	if err != nil {
 		r.Close()
 		if err != nil && !strings.Contains(err.Error(), sftp.ErrInternalInconsistency.Error()) {
 			return nil, fmt.Errorf("sftp: read (n=%d) %s: %w", n, r.Filename, err)
@@ -566,15 +1101,31 @@ func (c *client) Open(path string) (*File, error) {
 //
 // Follow the docs for fs.WalkDirFunc for details on traversal. Walk accepts fs.SkipDir to not process directories.
 func (c *client) Walk(dir string, fn fs.WalkDirFunc) error {
+	return c.WalkContext(context.Background(), dir, fn)
+}
+
+func (c *client) WalkContext(ctx context.Context, dir string, fn fs.WalkDirFunc) error {
+	ctx, op := c.startOperation(ctx, "walk", dir)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	return c.walkNoLock(dir, fn)
+	// Errors from fn are returned unchanged
+	var fnErr error
+	err := c.walkNoLock(ctx, dir, func(path string, d fs.DirEntry, err error) error {
+		fnErr = fn(path, d, err)
+		return fnErr
+	})
+	if err != nil && err == fnErr {
+		op.finish(nil)
+		return err
+	}
+	return op.finish(err)
 }
 
-func (c *client) walkNoLock(dir string, fn fs.WalkDirFunc) error {
-	conn, err := c.connection()
-	if err = c.clearConnectionOnError(err); err != nil {
+func (c *client) walkNoLock(ctx context.Context, dir string, fn fs.WalkDirFunc) error {
+	conn, err := c.connection(ctx)
+	if err = c.clearConnectionOnError(ctx, err); err != nil {
 		return err
 	}
 
@@ -588,7 +1139,7 @@ func (c *client) walkNoLock(dir string, fn fs.WalkDirFunc) error {
 	// Pass the callback to each file found
 	for w.Step() {
 		if err := w.Err(); err != nil {
-			return err
+			return c.clearConnectionOnError(ctx, err)
 		}
 
 		var skip bool
@@ -1,6 +1,13 @@
 package go_sftp
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/moov-io/base/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
 
 type ClientConfig struct {
 	Hostname string
@@ -11,6 +18,14 @@ type ClientConfig struct {
 	MaxConnections int
 	PacketSize     int
 
+	// ConnectRetryPolicy controls retries when establishing a connection.
+	// DefaultRetryPolicy is used when nil.
+	ConnectRetryPolicy *RetryPolicy
+
+	// OperationRetryPolicy retries ListFiles, Reader, Open and Delete after they failed because
+	// the connection was lost and it has been re-established. Operations are not retried when nil.
+	OperationRetryPolicy *RetryPolicy
+
 	// HostPublicKey configures an SSH public key to validate the remote server's host key.
 	// If provided, this key will be merged into HostPublicKeys.
 	// Deprecated: Use HostPublicKeys instead.
@@ -24,11 +39,161 @@ type ClientConfig struct {
 	ClientPrivateKey         string
 	ClientPrivateKeyPassword string // not base64 encoded
 
+	// Credentials is called on every connection to load the current credentials.
+	// Non-empty values override Password, ClientPrivateKey and ClientPrivateKeyPassword.
+	Credentials CredentialProvider
+
+	// KeyboardInteractive answers keyboard-interactive prompts which do not ask for a password,
+	// such as a one-time passcode. Password prompts are answered with Password.
+	KeyboardInteractive KeyboardInteractiveResponder
+
+	// AuthMethods is the order authentication methods are attempted in. Methods without
+	// credentials configured are skipped. Valid values are AuthPassword, AuthKeyboardInteractive
+	// and AuthPublicKey, which is also the default order.
+	AuthMethods []string
+
+	// AlgorithmPreset selects a named set of SSH algorithms to offer the remote server.
+	// Valid values are AlgorithmsModern, AlgorithmsCompatible and AlgorithmsLegacy.
+	// The golang.org/x/crypto/ssh defaults are used when empty.
+	AlgorithmPreset string
+
+	// Ciphers, KeyExchanges, MACs and HostKeyAlgorithms override the respective
+	// algorithms from AlgorithmPreset, in order of preference.
+	Ciphers           []string
+	KeyExchanges      []string
+	MACs              []string
+	HostKeyAlgorithms []string
+
+	// IsConnectionError marks additional errors as meaning the connection is no longer usable.
+	// The connection is closed and re-established after these errors, like it is for a lost
+	// connection, and operations are retried with OperationRetryPolicy.
+	IsConnectionError func(err error) bool
+
+	// KeepaliveInterval is how often keepalive@openssh.com requests are sent to detect a dead
+	// connection while the client is idle. While keepalives are answered operations skip
+	// checking the connection first. Keepalives are disabled when zero.
+	KeepaliveInterval time.Duration
+
+	// KeepaliveMaxMissed is how many keepalives in a row can go unanswered before the
+	// connection is closed. Defaults to 3.
+	KeepaliveMaxMissed int
+
+	// KeepaliveReconnect re-establishes the connection in the background after it was
+	// closed for missing keepalives, rather than on the next operation.
+	KeepaliveReconnect bool
+
+	// LazyConnect defers connecting to the server until the first operation, so NewClient only
+	// validates the config and parses keys. This avoids failing at startup when the server is down.
+	LazyConnect bool
+
+	// LazyConnectWarmup connects in the background after NewClient returns when LazyConnect is set.
+	// Ping and the sftp_agent_up metric report the connection status.
+	LazyConnectWarmup bool
+
+	// IdleTimeout closes the connection once no operation has run for this long.
+	// The next operation reconnects. Connections are kept open when zero.
+	IdleTimeout time.Duration
+
+	// OnConnect is called every time a connection is established. Returning an error closes
+	// the connection and fails the operation which was connecting.
+	OnConnect func(event ConnectionEvent) error
+
+	// OnDisconnect is called every time an open connection is closed.
+	OnDisconnect func(event ConnectionEvent)
+
+	// OnReconnect is called after OnConnect when a connection replaces one which was closed by an error.
+	OnReconnect func(event ConnectionEvent)
+
+	// MetricsRegisterer is where Prometheus metrics are registered.
+	// prometheus.DefaultRegisterer is used when nil.
+	MetricsRegisterer prometheus.Registerer
+
+	// MetricsLabels are added to every metric from this client, such as a client name or username,
+	// so clients connecting to the same host can be told apart. Clients sharing a MetricsRegisterer
+	// can use different label names. "hostname", "op" and "type" are reserved.
+	MetricsLabels map[string]string
+
+	// TracerProvider creates OpenTelemetry spans for every operation and its steps,
+	// such as connecting, mkdir, writing, sync and chmod. Tracing is disabled when nil.
+	TracerProvider trace.TracerProvider
+
+	// AuditLog records every upload, delete and ProbePermissions call, including failures, as hash-chained evidence.
+	// Use NewAuditFile for a JSON-lines file and VerifyAuditLog to check it.
+	AuditLog *AuditLog
+
+	// Compression compresses files as they're uploaded and decompresses them as they're read,
+	// either for every file or chosen by file extension. Compression happens before encryption.
+	Compression Compression
+
+	// Validators inspect the contents of files before they're uploaded and can reject them.
+	Validators []Validator
+
+	// UploadTransforms wrap the contents of every upload in order, before compression and encryption.
+	UploadTransforms []Transform
+
+	// DownloadTransforms wrap the contents of every file which is read in order, after
+	// decryption and decompression.
+	DownloadTransforms []Transform
+
+	// PGP encrypts uploaded files and decrypts files which are read. Files are unchanged when nil.
+	PGP *PGPConfig
+
+	// OperationLogLevels sets the level each completed operation is logged at, keyed by operation
+	// such as "upload", "delete", "list", "read", "open", "walk" or "ping". Operations which aren't
+	// listed are logged at debug and failed operations are always logged at error.
+	OperationLogLevels map[string]log.Level
+
+	// PathLogging controls how file paths appear in logs, such as redacting or hashing
+	// filenames which contain PII. Paths are logged unchanged by default.
+	PathLogging PathLogging
+
+	// AtomicUploads writes contents to a temporary file in the destination directory which is
+	// renamed into place once complete, so a partial file is never left at the upload path.
+	// This is required for UploadFile to be retried with OperationRetryPolicy.
+	AtomicUploads bool
+
 	SkipChmodAfterUpload  bool
 	SkipDirectoryCreation bool
 	SkipSyncAfterUpload   bool
 }
 
+// validate checks the config for problems which would prevent any connection from succeeding.
+func (cfg ClientConfig) validate() error {
+	if _, err := cfg.algorithms(); err != nil {
+		return fmt.Errorf("invalid SSH algorithms: %w", err)
+	}
+	if err := checkAuthMethods(cfg.AuthMethods); err != nil {
+		return err
+	}
+	if err := checkLogLevels(cfg.OperationLogLevels); err != nil {
+		return err
+	}
+	if err := cfg.PathLogging.validate(); err != nil {
+		return err
+	}
+	if err := cfg.Compression.validate(); err != nil {
+		return err
+	}
+	if err := checkValidators(cfg.Validators); err != nil {
+		return err
+	}
+	if !cfg.LazyConnect {
+		// Eager clients report invalid keys when connecting, which returns the client with the error
+		return nil
+	}
+	if hostKeys := cfg.HostKeys(); len(hostKeys) > 0 {
+		if _, err := newMultiKeyCallback(hostKeys); err != nil {
+			return err
+		}
+	}
+	if cfg.ClientPrivateKey != "" {
+		if _, err := readSigner(cfg.ClientPrivateKey, cfg.ClientPrivateKeyPassword); err != nil {
+			return fmt.Errorf("failed to read client private key: %w", err)
+		}
+	}
+	return nil
+}
+
 // HostKeys returns the list of configured public keys to use for host key verification.
 func (cfg ClientConfig) HostKeys() []string {
 	if cfg.HostPublicKey != "" {
@@ -0,0 +1,277 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/log"
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, server *sftptest.Server, configure func(cfg *sftp.ClientConfig)) sftp.ContextClient {
+	t.Helper()
+
+	cfg := &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Password:       sftptest.Password,
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+		HostPublicKeys: []string{server.HostKey()},
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	client, err := sftp.NewClient(log.NewTestLogger(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client.(sftp.ContextClient)
+}
+
+var testOperationRetryPolicy = &sftp.RetryPolicy{
+	MaxAttempts:     3,
+	InitialInterval: 10 * time.Millisecond,
+}
+
+func TestClient_OperationRetries(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.OperationRetryPolicy = testOperationRetryPolicy
+	})
+
+	require.NoError(t, client.UploadFile("/outbox/one.txt", io.NopCloser(strings.NewReader("one"))))
+
+	t.Run("ListFiles", func(t *testing.T) {
+		server.DropConnectionsOn("List")
+
+		files, err := client.ListFiles("/outbox")
+		require.NoError(t, err)
+		require.Equal(t, []string{"/outbox/one.txt"}, files)
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		server.DropConnectionsOn("Get")
+
+		file, err := client.Reader("/outbox/one.txt")
+		require.NoError(t, err)
+
+		bs, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "one", string(bs))
+		require.NoError(t, file.Close())
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		server.DropConnectionsOn("Get")
+
+		file, err := client.Open("/outbox/one.txt")
+		require.NoError(t, err)
+
+		bs, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "one", string(bs))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		server.DropConnectionsOn("Remove")
+
+		require.NoError(t, client.Delete("/outbox/one.txt"))
+
+		_, err := client.Reader("/outbox/one.txt")
+		require.Error(t, err)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		for i := 0; i < testOperationRetryPolicy.MaxAttempts; i++ {
+			server.DropConnectionsOn("List")
+		}
+		_, err := client.ListFiles("/outbox")
+		require.Error(t, err)
+	})
+}
+
+func TestClient_NoOperationRetries(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, nil)
+
+	server.DropConnectionsOn("List")
+	_, err := client.ListFiles("/outbox")
+	require.ErrorContains(t, err, "connection lost")
+
+	// The client has reconnected for the next call
+	_, err = client.ListFiles("/outbox")
+	require.NoError(t, err)
+}
+
+func TestClient_IsConnectionError(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.OperationRetryPolicy = testOperationRetryPolicy
+		})
+		require.NoError(t, client.UploadFile("/one.txt", io.NopCloser(strings.NewReader("one"))))
+
+		server.FailOn("Remove", syscall.EACCES)
+		require.ErrorIs(t, client.Delete("/one.txt"), os.ErrPermission)
+		require.Equal(t, 1, server.Connections())
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.OperationRetryPolicy = testOperationRetryPolicy
+			cfg.IsConnectionError = func(err error) bool {
+				return errors.Is(err, os.ErrPermission)
+			}
+		})
+		require.NoError(t, client.UploadFile("/one.txt", io.NopCloser(strings.NewReader("one"))))
+
+		// The client reconnects and retries the delete
+		server.FailOn("Remove", syscall.EACCES)
+		require.NoError(t, client.Delete("/one.txt"))
+		require.Equal(t, 2, server.Connections())
+	})
+}
+
+func TestClient_UploadRetries(t *testing.T) {
+	contents := func(t *testing.T) *os.File {
+		t.Helper()
+
+		path := filepath.Join(t.TempDir(), "upload.txt")
+		require.NoError(t, os.WriteFile(path, []byte("hello, world"), 0600))
+		fd, err := os.Open(path)
+		require.NoError(t, err)
+		return fd
+	}
+
+	t.Run("atomic", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.OperationRetryPolicy = testOperationRetryPolicy
+			cfg.AtomicUploads = true
+		})
+
+		// Fail after the contents have been written
+		server.DropConnectionsOn("Setstat")
+		require.NoError(t, client.UploadFile("/upload/file.txt", contents(t)))
+
+		file, err := client.Open("/upload/file.txt")
+		require.NoError(t, err)
+		bs, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "hello, world", string(bs))
+
+		// The temporary file was renamed into place
+		files, err := client.ListFiles("/upload")
+		require.NoError(t, err)
+		require.Equal(t, []string{"/upload/file.txt"}, files)
+	})
+
+	t.Run("atomic replaces existing file", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.AtomicUploads = true
+		})
+
+		require.NoError(t, client.UploadFile("/upload/file.txt", io.NopCloser(strings.NewReader("first"))))
+		require.NoError(t, client.UploadFile("/upload/file.txt", io.NopCloser(strings.NewReader("second"))))
+
+		file, err := client.Open("/upload/file.txt")
+		require.NoError(t, err)
+		bs, err := io.ReadAll(file)
+		require.NoError(t, err)
+		require.Equal(t, "second", string(bs))
+	})
+
+	t.Run("not atomic", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.OperationRetryPolicy = testOperationRetryPolicy
+		})
+
+		server.DropConnectionsOn("Setstat")
+		require.Error(t, client.UploadFile("/upload/file.txt", contents(t)))
+	})
+
+	t.Run("contents not seekable", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.OperationRetryPolicy = testOperationRetryPolicy
+			cfg.AtomicUploads = true
+		})
+
+		server.DropConnectionsOn("Setstat")
+		require.Error(t, client.UploadFile("/upload/file.txt", io.NopCloser(strings.NewReader("hello, world"))))
+
+		_, err := client.Reader("/upload/file.txt")
+		require.Error(t, err)
+	})
+
+	t.Run("source errors", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.OperationRetryPolicy = testOperationRetryPolicy
+			cfg.AtomicUploads = true
+		})
+
+		// Errors reading contents aren't mistaken for the connection being lost
+		source := &failingFile{File: contents(t), err: io.ErrClosedPipe}
+		err := client.UploadFile("/upload/file.txt", source)
+		require.ErrorIs(t, err, io.ErrClosedPipe)
+		require.Equal(t, 1, source.reads)
+		require.Equal(t, 1, server.Connections())
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+
+		var calls int
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.OperationRetryPolicy = testOperationRetryPolicy
+			cfg.AtomicUploads = true
+			cfg.Validators = []sftp.Validator{{
+				Stream: true,
+				Validate: func(string, io.Reader) error {
+					calls++
+					return io.ErrUnexpectedEOF
+				},
+			}}
+		})
+
+		// Errors from the validator aren't mistaken for the connection being lost
+		err := client.UploadFile("/upload/file.txt", contents(t))
+		var verr *sftp.ValidationError
+		require.ErrorAs(t, err, &verr)
+		require.NotErrorIs(t, err, sftp.ErrConnectionLost)
+		require.Equal(t, 1, calls)
+		require.Equal(t, 1, server.Connections())
+	})
+}
+
+// failingFile is a seekable file which fails every read with err.
+type failingFile struct {
+	*os.File
+
+	err   error
+	reads int
+}
+
+func (f *failingFile) Read([]byte) (int, error) {
+	f.reads++
+	return 0, f.err
+}
@@ -19,6 +19,7 @@ import (
 
 	"github.com/moov-io/base/log"
 	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
 
 	"github.com/stretchr/testify/require"
 )
@@ -37,6 +38,69 @@ func TestClientErr(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestClient_LazyConnect(t *testing.T) {
+	t.Run("server down", func(t *testing.T) {
+		client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+			Hostname:    "localhost:invalid",
+			LazyConnect: true,
+			ConnectRetryPolicy: &sftp.RetryPolicy{
+				MaxAttempts: 1,
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, client)
+		require.Error(t, client.Ping())
+	})
+
+	t.Run("invalid host key", func(t *testing.T) {
+		client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+			Hostname:       "localhost:invalid",
+			LazyConnect:    true,
+			HostPublicKeys: []string{"ssh-ed25519 invalid"},
+		})
+		require.ErrorContains(t, err, "reading host key at index 0")
+		require.Nil(t, client)
+	})
+
+	t.Run("invalid host key without LazyConnect", func(t *testing.T) {
+		client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+			Hostname:       "localhost:invalid",
+			HostPublicKeys: []string{"ssh-ed25519 invalid"},
+			ConnectRetryPolicy: &sftp.RetryPolicy{
+				MaxAttempts: 1,
+			},
+		})
+		require.ErrorContains(t, err, "reading host key at index 0")
+		require.NotNil(t, client)
+	})
+
+	t.Run("connect on first operation", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.LazyConnect = true
+		})
+		require.Equal(t, 0, server.Connections())
+
+		_, err := client.ListFiles("/")
+		require.NoError(t, err)
+		require.Equal(t, 1, server.Connections())
+	})
+
+	t.Run("warmup", func(t *testing.T) {
+		server := sftptest.NewServer(t)
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.LazyConnect = true
+			cfg.LazyConnectWarmup = true
+		})
+		require.Eventually(t, func() bool {
+			return server.Connections() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, client.Ping())
+		require.Equal(t, 1, server.Connections())
+	})
+}
+
 func TestClient(t *testing.T) {
 	if testing.Short() {
 		t.Skip("-short flag was provided")
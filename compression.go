@@ -0,0 +1,76 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Compression selects how files are compressed as they're uploaded and decompressed as they're read.
+type Compression string
+
+const (
+	// CompressionNone uploads and reads files unchanged.
+	CompressionNone Compression = ""
+
+	// CompressionGzip compresses every file with gzip.
+	CompressionGzip Compression = "gzip"
+
+	// CompressionAuto chooses by file extension, so only paths ending in .gz are compressed with gzip.
+	CompressionAuto Compression = "auto"
+)
+
+func (c Compression) validate() error {
+	switch c {
+	case CompressionNone, CompressionGzip, CompressionAuto:
+		return nil
+	}
+	return fmt.Errorf("unknown Compression %q", c)
+}
+
+// forPath returns the compression used for path.
+func (c Compression) forPath(path string) Compression {
+	if c == CompressionAuto {
+		if strings.HasSuffix(strings.ToLower(path), ".gz") {
+			return CompressionGzip
+		}
+		return CompressionNone
+	}
+	return c
+}
+
+// compress returns a reader of the compressed contents of r.
+// Compression happens as the returned reader is consumed.
+func (c Compression) compress(r io.Reader) io.ReadCloser {
+	return pipeReader(func(w io.Writer) error {
+		gz := gzip.NewWriter(w)
+		if _, err := io.Copy(gz, r); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+}
+
+// decompress returns a reader of the decompressed contents of rc, which is closed with the returned reader.
+func (c Compression) decompress(rc io.ReadCloser) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	return &decompressingReader{Reader: gz, closer: rc}, nil
+}
+
+type decompressingReader struct {
+	*gzip.Reader
+	closer io.Closer
+}
+
+func (r *decompressingReader) Close() error {
+	r.Reader.Close()
+	return r.closer.Close()
+}
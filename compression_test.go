@@ -0,0 +1,91 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Compression(t *testing.T) {
+	server := sftptest.NewServer(t)
+	plain := newTestClient(t, server, nil)
+
+	contents := strings.Repeat("reconciliation,", 1000)
+
+	auto := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.Compression = sftp.CompressionAuto
+	})
+	require.NoError(t, auto.UploadFile("/recon.csv.gz", io.NopCloser(strings.NewReader(contents))))
+	require.NoError(t, auto.UploadFile("/recon.csv", io.NopCloser(strings.NewReader(contents))))
+
+	// Only the .gz file is compressed on the server
+	compressed := readAll(t, plain, "/recon.csv.gz")
+	require.Less(t, len(compressed), len(contents))
+	gz, err := gzip.NewReader(strings.NewReader(compressed))
+	require.NoError(t, err)
+	bs, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, contents, string(bs))
+	require.Equal(t, contents, readAll(t, plain, "/recon.csv"))
+
+	require.Equal(t, contents, readAll(t, auto, "/recon.csv.gz"))
+	require.Equal(t, contents, readAll(t, auto, "/recon.csv"))
+
+	file, err := auto.Open("/recon.csv.gz")
+	require.NoError(t, err)
+	bs, err = io.ReadAll(file.Contents)
+	require.NoError(t, err)
+	require.Equal(t, contents, string(bs))
+
+	// Compressed before encrypting
+	keys := newPGPKeys(t, "")
+	encrypted := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.Compression = sftp.CompressionGzip
+		cfg.PGP = &sftp.PGPConfig{
+			RecipientPublicKeys: []string{keys.public},
+			PrivateKey:          keys.private,
+		}
+	})
+	require.NoError(t, encrypted.UploadFile("/recon.pgp", io.NopCloser(strings.NewReader(contents))))
+	require.Equal(t, contents, readAll(t, encrypted, "/recon.pgp"))
+
+	// Files which aren't compressed fail to read
+	gzipped := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.Compression = sftp.CompressionGzip
+	})
+	_, err = gzipped.Reader("/recon.csv")
+	require.ErrorContains(t, err, "gzip: invalid header")
+
+	// Malformed files fail without being mistaken for the connection being lost
+	retried := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.Compression = sftp.CompressionAuto
+		cfg.OperationRetryPolicy = testOperationRetryPolicy
+	})
+	require.NoError(t, plain.UploadFile("/empty.csv.gz", io.NopCloser(strings.NewReader(""))))
+	require.NoError(t, plain.UploadFile("/truncated.csv.gz", io.NopCloser(strings.NewReader(compressed[:len(compressed)/2]))))
+	for _, path := range []string{"/empty.csv.gz", "/truncated.csv.gz"} {
+		gets := server.Requests("Get")
+		_, err := retried.Open(path)
+		require.Error(t, err, path)
+		require.NotErrorIs(t, err, sftp.ErrConnectionLost, path)
+		require.Equal(t, gets+1, server.Requests("Get"), path)
+	}
+
+	_, err = sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:    server.Addr(),
+		LazyConnect: true,
+		Compression: "lz4",
+	})
+	require.ErrorContains(t, err, `unknown Compression "lz4"`)
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Credentials are the secrets used to authenticate with the remote server.
+type Credentials struct {
+	Password string
+
+	// ClientPrivateKey can be base64 encoded or PEM
+	ClientPrivateKey         string
+	ClientPrivateKeyPassword string
+}
+
+// CredentialProvider returns the current credentials for a server.
+//
+// Credentials is called on every connection attempt, including reconnects and retries after
+// the server rejected authentication, so rotated secrets are used without restarting.
+type CredentialProvider interface {
+	Credentials() (Credentials, error)
+}
+
+// FileCredentialProvider reads credentials from files on every call.
+// Files are typically mounted from a secrets manager. Empty paths are skipped.
+type FileCredentialProvider struct {
+	PasswordPath                 string
+	ClientPrivateKeyPath         string
+	ClientPrivateKeyPasswordPath string
+}
+
+var _ CredentialProvider = (&FileCredentialProvider{})
+
+func (p *FileCredentialProvider) Credentials() (Credentials, error) {
+	var creds Credentials
+	var err error
+
+	if creds.Password, err = readCredentialFile(p.PasswordPath); err != nil {
+		return creds, err
+	}
+	if creds.ClientPrivateKey, err = readCredentialFile(p.ClientPrivateKeyPath); err != nil {
+		return creds, err
+	}
+	if creds.ClientPrivateKeyPassword, err = readCredentialFile(p.ClientPrivateKeyPasswordPath); err != nil {
+		return creds, err
+	}
+	return creds, nil
+}
+
+func readCredentialFile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading credentials: %w", err)
+	}
+	return strings.TrimRight(string(bs), "\r\n"), nil
+}
+
+// EnvCredentialProvider reads credentials from environment variables on every call.
+// Empty variable names are skipped.
+type EnvCredentialProvider struct {
+	PasswordEnv                 string
+	ClientPrivateKeyEnv         string
+	ClientPrivateKeyPasswordEnv string
+}
+
+var _ CredentialProvider = (&EnvCredentialProvider{})
+
+func (p *EnvCredentialProvider) Credentials() (Credentials, error) {
+	lookup := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return os.Getenv(name)
+	}
+	return Credentials{
+		Password:                 lookup(p.PasswordEnv),
+		ClientPrivateKey:         lookup(p.ClientPrivateKeyEnv),
+		ClientPrivateKeyPassword: lookup(p.ClientPrivateKeyPasswordEnv),
+	}, nil
+}
+
+// withCredentials returns a copy of cfg with any credentials from its CredentialProvider applied.
+// Empty values from the provider leave the configured values in place.
+func (cfg ClientConfig) withCredentials() (ClientConfig, error) {
+	if cfg.Credentials == nil {
+		return cfg, nil
+	}
+	creds, err := cfg.Credentials.Credentials()
+	if err != nil {
+		return cfg, fmt.Errorf("loading credentials: %w", err)
+	}
+	if creds.Password != "" {
+		cfg.Password = creds.Password
+	}
+	if creds.ClientPrivateKey != "" {
+		cfg.ClientPrivateKey = creds.ClientPrivateKey
+	}
+	if creds.ClientPrivateKeyPassword != "" {
+		cfg.ClientPrivateKeyPassword = creds.ClientPrivateKeyPassword
+	}
+	return cfg, nil
+}
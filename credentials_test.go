@@ -0,0 +1,182 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/moov-io/base/log"
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// rotatingServer returns a server which only accepts the current password.
+func rotatingServer(t *testing.T, password string) (*sftptest.Server, func(string)) {
+	t.Helper()
+
+	var mu sync.Mutex
+	server := sftptest.NewServer(t)
+	server.Config.PasswordCallback = func(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if string(pass) == password {
+			return nil, nil
+		}
+		return nil, errors.New("invalid password")
+	}
+	return server, func(newPassword string) {
+		mu.Lock()
+		defer mu.Unlock()
+		password = newPassword
+	}
+}
+
+func TestFileCredentialProvider(t *testing.T) {
+	server, rotate := rotatingServer(t, "first")
+
+	passwordPath := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordPath, []byte("first\n"), 0600))
+
+	client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Password:       "static",
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+		Credentials: &sftp.FileCredentialProvider{
+			PasswordPath: passwordPath,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	// Rotate the password and force a reconnect
+	rotate("second")
+	require.NoError(t, os.WriteFile(passwordPath, []byte("second\n"), 0600))
+	server.DropConnections()
+
+	require.NoError(t, client.Ping())
+}
+
+func TestFileCredentialProvider_RefreshAfterAuthFailure(t *testing.T) {
+	server, _ := rotatingServer(t, "second")
+
+	passwordPath := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordPath, []byte("first"), 0600))
+
+	// Update the mounted secret once the server rejects the stale password
+	var once sync.Once
+	server.Config.AuthLogCallback = func(conn ssh.ConnMetadata, method string, err error) {
+		if method == "password" && err != nil {
+			once.Do(func() {
+				os.WriteFile(passwordPath, []byte("second"), 0600)
+			})
+		}
+	}
+
+	client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+		Credentials: &sftp.FileCredentialProvider{
+			PasswordPath: passwordPath,
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	require.NoError(t, client.Ping())
+}
+
+func TestFileCredentialProvider_Missing(t *testing.T) {
+	provider := &sftp.FileCredentialProvider{
+		PasswordPath: filepath.Join(t.TempDir(), "missing"),
+	}
+	_, err := provider.Credentials()
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestEnvCredentialProvider(t *testing.T) {
+	t.Setenv("SFTP_TEST_PASSWORD", "secret")
+	t.Setenv("SFTP_TEST_KEY_PASSWORD", "passphrase")
+
+	provider := &sftp.EnvCredentialProvider{
+		PasswordEnv:                 "SFTP_TEST_PASSWORD",
+		ClientPrivateKeyPasswordEnv: "SFTP_TEST_KEY_PASSWORD",
+	}
+	creds, err := provider.Credentials()
+	require.NoError(t, err)
+	require.Equal(t, sftp.Credentials{
+		Password:                 "secret",
+		ClientPrivateKeyPassword: "passphrase",
+	}, creds)
+
+	// Changes are picked up on the next call
+	t.Setenv("SFTP_TEST_PASSWORD", "rotated")
+	creds, err = provider.Credentials()
+	require.NoError(t, err)
+	require.Equal(t, "rotated", creds.Password)
+}
+
+func TestClient_AuthFailureNotRetried(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+
+	server := sftptest.NewServer(t)
+	server.Config.AuthLogCallback = func(conn ssh.ConnMetadata, method string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if method == "password" {
+			attempts++
+		}
+	}
+
+	client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Password:       "wrong",
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+	})
+	require.ErrorContains(t, err, "unable to authenticate")
+	t.Cleanup(func() { client.Close() })
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 1, attempts)
+}
+
+func TestClient_CredentialsUnreachableHost(t *testing.T) {
+	passwordPath := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordPath, []byte("secret"), 0600))
+
+	// Connection errors are still classified by the original Retryable
+	client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:       "127.0.0.1:1",
+		Username:       sftptest.Username,
+		Timeout:        time.Second,
+		MaxConnections: 1,
+		Credentials: &sftp.FileCredentialProvider{
+			PasswordPath: passwordPath,
+		},
+		ConnectRetryPolicy: &sftp.RetryPolicy{
+			MaxAttempts:     2,
+			InitialInterval: time.Millisecond,
+		},
+	})
+	require.ErrorContains(t, err, "connection refused")
+	if client != nil {
+		client.Close()
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/sftp"
+)
+
+// Errors returned by Client can be checked against these with errors.Is
+//
+//	if errors.Is(err, go_sftp.ErrNotFound) { ... }
+var (
+	ErrNotFound         = errors.New("sftp: not found")
+	ErrPermissionDenied = errors.New("sftp: permission denied")
+	ErrAuthFailed       = errors.New("sftp: authentication failed")
+	ErrHostKeyMismatch  = errors.New("sftp: host key mismatch")
+	ErrConnectionLost   = errors.New("sftp: connection lost")
+	ErrUnsupported      = errors.New("sftp: operation unsupported")
+	ErrQuotaExceeded    = errors.New("sftp: quota exceeded")
+	ErrInvalidSignature = errors.New("sftp: invalid signature")
+)
+
+// SFTP status codes which github.com/pkg/sftp doesn't export
+const (
+	sshFxNoSuchFile       = 2
+	sshFxPermissionDenied = 3
+	sshFxOpUnsupported    = 8
+	sshFxQuotaExceeded    = 15
+)
+
+// Error records the operation and remote path which failed.
+//
+// Error matches the sentinel errors (ErrNotFound, ErrPermissionDenied, etc) with errors.Is
+// according to the underlying error, and unwraps to that error.
+type Error struct {
+	Op   string // such as "connect", "ping", "delete", "upload", "list", "read", "open" or "walk"
+	Path string // remote path, if any
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the underlying error belongs to target's class of errors.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return isNotFound(e.Err)
+	case ErrPermissionDenied:
+		return isPermissionDenied(e.Err)
+	case ErrAuthFailed:
+		return isAuthError(e.Err)
+	case ErrHostKeyMismatch:
+		return errors.Is(e.Err, errNoMatchingHostKeys)
+	case ErrConnectionLost:
+		return isConnectionClosed(e.Err)
+	case ErrUnsupported:
+		return isUnsupported(e.Err)
+	case ErrQuotaExceeded:
+		return isQuotaExceeded(e.Err)
+	}
+	return false
+}
+
+// errorType returns a short name for the class of err, used to label metrics.
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, errNoMatchingHostKeys):
+		return "host_key_mismatch"
+	case errors.Is(err, ErrInvalidSignature):
+		return "invalid_signature"
+	case errors.As(err, new(*ValidationError)):
+		return "validation_failed"
+	case isAuthError(err):
+		return "auth_failed"
+	case isNotFound(err):
+		return "not_found"
+	case isPermissionDenied(err):
+		return "permission_denied"
+	case isQuotaExceeded(err):
+		return "quota_exceeded"
+	case isUnsupported(err):
+		return "unsupported"
+	case isConnectionClosed(err):
+		return "connection_lost"
+	}
+	return "other"
+}
+
+// newError wraps err with the operation and path unless it's nil or already an *Error.
+func newError(op, path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{Op: op, Path: path, Err: err}
+}
+
+func statusCode(err error) (uint32, bool) {
+	var status *sftp.StatusError
+	if errors.As(err, &status) {
+		return status.Code, true
+	}
+	return 0, false
+}
+
+func isNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	if code, ok := statusCode(err); ok && code == sshFxNoSuchFile {
+		return true
+	}
+	return errors.Is(err, fs.ErrNotExist) ||
+		errors.Is(err, sftp.ErrSSHFxNoSuchFile) ||
+		strings.Contains(err.Error(), "file does not exist")
+}
+
+func isPermissionDenied(err error) bool {
+	if err == nil {
+		return false
+	}
+	if code, ok := statusCode(err); ok && code == sshFxPermissionDenied {
+		return true
+	}
+	return errors.Is(err, fs.ErrPermission) ||
+		errors.Is(err, sftp.ErrSSHFxPermissionDenied)
+}
+
+func isUnsupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	if code, ok := statusCode(err); ok && code == sshFxOpUnsupported {
+		return true
+	}
+	return errors.Is(err, errors.ErrUnsupported) ||
+		errors.Is(err, sftp.ErrSSHFxOpUnsupported) ||
+		strings.Contains(err.Error(), "SSH_FX_OP_UNSUPPORTED")
+}
+
+func isQuotaExceeded(err error) bool {
+	if err == nil {
+		return false
+	}
+	if code, ok := statusCode(err); ok && code == sshFxQuotaExceeded {
+		return true
+	}
+	if errors.Is(err, syscall.EDQUOT) || errors.Is(err, syscall.ENOSPC) {
+		return true
+	}
+	// Most servers only speak SFTP v3 and report quota errors as SSH_FX_FAILURE
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "quota exceeded") || strings.Contains(msg, "no space left")
+}
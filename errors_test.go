@@ -0,0 +1,157 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/moov-io/base/log"
+	pkgsftp "github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Errors(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, nil)
+	require.NoError(t, client.UploadFile("/one.txt", io.NopCloser(strings.NewReader("one"))))
+
+	tests := []struct {
+		name     string
+		fault    func()
+		call     func() error
+		op, path string
+		expected error
+	}{
+		{
+			name:     "not found",
+			call:     func() error { _, err := client.Reader("/missing.txt"); return err },
+			op:       "read",
+			path:     "/missing.txt",
+			expected: sftp.ErrNotFound,
+		},
+		{
+			name:     "permission denied",
+			fault:    func() { server.FailOn("Put", syscall.EACCES) },
+			call:     func() error { return client.UploadFile("/two.txt", io.NopCloser(strings.NewReader("two"))) },
+			op:       "upload",
+			path:     "/two.txt",
+			expected: sftp.ErrPermissionDenied,
+		},
+		{
+			name:     "unsupported",
+			fault:    func() { server.FailOn("Remove", pkgsftp.ErrSSHFxOpUnsupported) },
+			call:     func() error { return client.Delete("/one.txt") },
+			op:       "delete",
+			path:     "/one.txt",
+			expected: sftp.ErrUnsupported,
+		},
+		{
+			name:     "quota exceeded",
+			fault:    func() { server.FailOn("Put", errors.New("Quota exceeded")) },
+			call:     func() error { return client.UploadFile("/two.txt", io.NopCloser(strings.NewReader("two"))) },
+			op:       "upload",
+			path:     "/two.txt",
+			expected: sftp.ErrQuotaExceeded,
+		},
+		{
+			name:     "connection lost",
+			fault:    func() { server.DropConnectionsOn("List") },
+			call:     func() error { _, err := client.ListFiles("/"); return err },
+			op:       "list",
+			path:     "/",
+			expected: sftp.ErrConnectionLost,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.fault != nil {
+				tt.fault()
+			}
+			err := tt.call()
+			require.ErrorIs(t, err, tt.expected)
+
+			var serr *sftp.Error
+			require.ErrorAs(t, err, &serr)
+			require.Equal(t, tt.op, serr.Op)
+			require.Equal(t, tt.path, serr.Path)
+
+			for _, other := range []error{sftp.ErrNotFound, sftp.ErrPermissionDenied, sftp.ErrAuthFailed, sftp.ErrHostKeyMismatch, sftp.ErrConnectionLost} {
+				if other != tt.expected {
+					require.NotErrorIs(t, err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_ConnectErrors(t *testing.T) {
+	server := sftptest.NewServer(t)
+
+	tests := []struct {
+		name      string
+		configure func(cfg *sftp.ClientConfig)
+		expected  error
+	}{
+		{
+			name: "auth failed",
+			configure: func(cfg *sftp.ClientConfig) {
+				cfg.Password = "wrong"
+			},
+			expected: sftp.ErrAuthFailed,
+		},
+		{
+			name: "host key mismatch",
+			configure: func(cfg *sftp.ClientConfig) {
+				cfg.HostPublicKeys = []string{sftptest.NewServer(t).HostKey()}
+			},
+			expected: sftp.ErrHostKeyMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &sftp.ClientConfig{
+				Hostname:       server.Addr(),
+				Username:       sftptest.Username,
+				Password:       sftptest.Password,
+				Timeout:        5 * time.Second,
+				MaxConnections: 1,
+				HostPublicKeys: []string{server.HostKey()},
+			}
+			tt.configure(cfg)
+
+			_, err := sftp.NewClient(log.NewTestLogger(), cfg)
+			require.ErrorIs(t, err, tt.expected)
+
+			var serr *sftp.Error
+			require.ErrorAs(t, err, &serr)
+			require.Equal(t, "connect", serr.Op)
+		})
+	}
+}
+
+func TestMockClient_Errors(t *testing.T) {
+	client := sftp.NewMockClient(t)
+
+	_, err := client.Open("/missing.txt")
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+
+	var serr *sftp.Error
+	require.ErrorAs(t, err, &serr)
+	require.Equal(t, "open", serr.Op)
+	require.Equal(t, "/missing.txt", serr.Path)
+
+	client.Err = pkgsftp.ErrSSHFxPermissionDenied
+	err = client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("contents")))
+	require.ErrorIs(t, err, sftp.ErrPermissionDenied)
+	require.ErrorIs(t, err, client.Err)
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// ConnectionEvent describes a change to the connection with the remote server.
+//
+// Hooks are called while the Client is locked, so they must not call methods on the Client.
+// Use the SFTP field for any per-connection setup.
+type ConnectionEvent struct {
+	Hostname string
+
+	// Attempt is how many dials it took to connect. It's zero for OnDisconnect.
+	Attempt int
+
+	// Duration is how long connecting took for OnConnect and OnReconnect,
+	// and how long the connection was open for OnDisconnect.
+	Duration time.Duration
+
+	// Cause is the error which closed the connection for OnDisconnect and OnReconnect.
+	// It's nil when the connection was closed by Close or IdleTimeout.
+	Cause error
+
+	// ServerVersion and Banner are sent by the server while connecting.
+	ServerVersion string
+	Banner        string
+
+	// SFTP is the newly established connection for OnConnect and OnReconnect.
+	SFTP *sftp.Client
+}
+
+// connectInfo is collected by sftpConnect while establishing a connection.
+type connectInfo struct {
+	attempts int
+	banner   string
+}
+
+// connected is called by connection() once a new connection is established.
+// An error from OnConnect means the connection should not be used.
+//
+// connected must be called within a mutex lock.
+func (c *client) connected(conn *ssh.Client, client *sftp.Client, info connectInfo, took time.Duration) error {
+	c.connectedAt = time.Now()
+	c.stats.connected()
+
+	server := newServerInfo(conn, info.banner)
+	c.serverInfo.Store(&server)
+	c.logServerInfo(server)
+
+	event := ConnectionEvent{
+		Hostname:      c.cfg.Hostname,
+		Attempt:       info.attempts,
+		Duration:      took,
+		ServerVersion: server.ServerVersion,
+		Banner:        server.Banner,
+		SFTP:          client,
+	}
+	if c.cfg.OnConnect != nil {
+		if err := c.cfg.OnConnect(event); err != nil {
+			return fmt.Errorf("sftp: OnConnect: %w", err)
+		}
+	}
+
+	// Report reconnects after the previous connection failed
+	if cause := c.disconnectCause; cause != nil {
+		c.disconnectCause = nil
+		if c.cfg.OnReconnect != nil {
+			event.Cause = cause
+			c.cfg.OnReconnect(event)
+		}
+	}
+	return nil
+}
+
+// disconnected is called by teardown() after an open connection is closed.
+//
+// disconnected must be called within a mutex lock.
+func (c *client) disconnected(cause error) {
+	c.stats.disconnected()
+	if cause != nil {
+		c.disconnectCause = cause
+	}
+	if c.cfg.OnDisconnect != nil {
+		c.cfg.OnDisconnect(ConnectionEvent{
+			Hostname: c.cfg.Hostname,
+			Duration: time.Since(c.connectedAt),
+			Cause:    cause,
+		})
+	}
+}
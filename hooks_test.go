@@ -0,0 +1,130 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+type connectionEvents struct {
+	mu     sync.Mutex
+	events []string
+	last   map[string]sftp.ConnectionEvent
+}
+
+func (e *connectionEvents) hooks(cfg *sftp.ClientConfig) {
+	e.last = make(map[string]sftp.ConnectionEvent)
+
+	record := func(name string, event sftp.ConnectionEvent) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		e.events = append(e.events, name)
+		e.last[name] = event
+	}
+	cfg.OnConnect = func(event sftp.ConnectionEvent) error {
+		record("connect", event)
+		return nil
+	}
+	cfg.OnDisconnect = func(event sftp.ConnectionEvent) {
+		record("disconnect", event)
+	}
+	cfg.OnReconnect = func(event sftp.ConnectionEvent) {
+		record("reconnect", event)
+	}
+}
+
+func (e *connectionEvents) get() ([]string, map[string]sftp.ConnectionEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]string(nil), e.events...), e.last
+}
+
+func TestClient_ConnectionHooks(t *testing.T) {
+	server := sftptest.NewServer(t)
+	server.Config.BannerCallback = func(conn ssh.ConnMetadata) string {
+		return "maintenance on Sunday\n"
+	}
+
+	var events connectionEvents
+	client := newTestClient(t, server, events.hooks)
+
+	names, last := events.get()
+	require.Equal(t, []string{"connect"}, names)
+
+	connect := last["connect"]
+	require.Equal(t, server.Addr(), connect.Hostname)
+	require.Equal(t, 1, connect.Attempt)
+	require.Greater(t, connect.Duration, time.Duration(0))
+	require.Nil(t, connect.Cause)
+	require.Equal(t, "SSH-2.0-Go", connect.ServerVersion)
+	require.Equal(t, "maintenance on Sunday\n", connect.Banner)
+	require.NotNil(t, connect.SFTP)
+
+	// Drop the connection while listing files
+	server.DropConnectionsOn("List")
+	_, err := client.ListFiles("/")
+	require.ErrorIs(t, err, sftp.ErrConnectionLost)
+
+	names, last = events.get()
+	require.Equal(t, []string{"connect", "disconnect", "connect", "reconnect"}, names)
+	require.ErrorContains(t, last["disconnect"].Cause, "connection lost")
+	require.Greater(t, last["disconnect"].Duration, time.Duration(0))
+	require.Equal(t, last["disconnect"].Cause, last["reconnect"].Cause)
+	require.Equal(t, "SSH-2.0-Go", last["reconnect"].ServerVersion)
+
+	// Closing the client isn't caused by an error
+	require.NoError(t, client.Close())
+
+	names, last = events.get()
+	require.Equal(t, []string{"connect", "disconnect", "connect", "reconnect", "disconnect"}, names)
+	require.Nil(t, last["disconnect"].Cause)
+}
+
+func TestClient_OnConnectError(t *testing.T) {
+	server := sftptest.NewServer(t)
+
+	var disconnects int
+	cfg := &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Password:       sftptest.Password,
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+		HostPublicKeys: []string{server.HostKey()},
+		OnConnect: func(event sftp.ConnectionEvent) error {
+			_, err := event.SFTP.Stat("/home")
+			return err
+		},
+		OnDisconnect: func(event sftp.ConnectionEvent) {
+			disconnects++
+		},
+	}
+	_, err := sftp.NewClient(log.NewTestLogger(), cfg)
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+	require.ErrorContains(t, err, "OnConnect")
+	require.Equal(t, 1, disconnects)
+
+	// The setup succeeds once the directory exists
+	other := newTestClient(t, server, nil)
+	require.NoError(t, other.UploadFile("/home/file.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	client, err := sftp.NewClient(log.NewTestLogger(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+	require.Equal(t, 2, disconnects)
+}
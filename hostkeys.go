@@ -2,28 +2,74 @@ package go_sftp
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/moov-io/go-sftp/pkg/sshx"
 	"golang.org/x/crypto/ssh"
 )
 
+var errNoMatchingHostKeys = errors.New("sftp: no matching host keys")
+
 type MultiKeyCallback struct {
 	hostKeys []ssh.PublicKey
+
+	// fingerprints holds OpenSSH SHA256 ("SHA256:...") and legacy MD5 ("aa:bb:...") fingerprints
+	fingerprints []string
 }
 
+// NewMultiKeyCallback returns an ssh.HostKeyCallback which accepts any of the provided keys.
+//
+// Each key can be a full public key (see sshx.ReadPubKey), an OpenSSH SHA256 fingerprint
+// such as "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8" or a legacy MD5 fingerprint
+// such as "16:27:ac:a5:76:28:2d:36:63:1b:56:4d:eb:df:a6:48".
 func NewMultiKeyCallback(keys []string) (ssh.HostKeyCallback, error) {
+	m, err := newMultiKeyCallback(keys)
+	if err != nil {
+		return nil, err
+	}
+	return m.check, nil
+}
+
+func newMultiKeyCallback(keys []string) (*MultiKeyCallback, error) {
 	m := &MultiKeyCallback{}
 	for i := range keys {
+		if fp, ok := parseFingerprint(keys[i]); ok {
+			m.fingerprints = append(m.fingerprints, fp)
+			continue
+		}
 		pubKey, err := sshx.ReadPubKey([]byte(keys[i]))
 		if err != nil {
 			return nil, fmt.Errorf("sftp: reading host key at index %d: %w", i, err)
 		}
 		m.hostKeys = append(m.hostKeys, pubKey)
 	}
-	return m.check, nil
+	return m, nil
+}
+
+// hostKeyAlgorithms returns the SSH host key algorithms able to produce one of the
+// configured keys, so the server is asked for a key we can verify.
+//
+// No algorithms are returned when fingerprints are configured as their key type is unknown.
+func (m *MultiKeyCallback) hostKeyAlgorithms() []string {
+	if len(m.fingerprints) > 0 {
+		return nil
+	}
+	var out []string
+	for _, key := range m.hostKeys {
+		switch key.Type() {
+		case ssh.KeyAlgoRSA:
+			out = append(out, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			out = append(out, key.Type())
+		}
+	}
+	return dedupe(out)
 }
 
 // check is an ssh.HostKeyCallback based on ssh.FixedHostKey, running the equality check against each configured key.
@@ -33,5 +79,40 @@ func (m *MultiKeyCallback) check(_ string, _ net.Addr, key ssh.PublicKey) error
 			return nil
 		}
 	}
-	return errors.New("sftp: no matching host keys")
+
+	sha256Fingerprint := ssh.FingerprintSHA256(key)
+	md5Fingerprint := ssh.FingerprintLegacyMD5(key)
+	for _, fp := range m.fingerprints {
+		if fp == sha256Fingerprint || fp == md5Fingerprint {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w, server presented %s %s", errNoMatchingHostKeys, key.Type(), sha256Fingerprint)
+}
+
+// parseFingerprint returns the normalized fingerprint if key is formatted as
+// an OpenSSH SHA256 or legacy MD5 fingerprint.
+func parseFingerprint(key string) (string, bool) {
+	key = strings.TrimSpace(key)
+
+	if strings.HasPrefix(key, "SHA256:") {
+		fp := strings.TrimRight(key, "=")
+		digest, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(fp, "SHA256:"))
+		if err != nil || len(digest) != sha256.Size {
+			return "", false
+		}
+		return fp, true
+	}
+
+	md5 := strings.ToLower(strings.TrimPrefix(key, "MD5:"))
+	if parts := strings.Split(md5, ":"); len(parts) == 16 {
+		for _, p := range parts {
+			if _, err := hex.DecodeString(p); err != nil || len(p) != 2 {
+				return "", false
+			}
+		}
+		return md5, true
+	}
+
+	return "", false
 }
@@ -1,9 +1,15 @@
 package go_sftp_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/moov-io/base/log"
 	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/ssh"
 )
@@ -65,3 +71,109 @@ func TestMultiKeyCallback_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiKeyCallback_Fingerprints(t *testing.T) {
+	rsaHostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(rsaKey))
+	require.NoError(t, err)
+	ed25519HostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ed25519Key))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr bool
+	}{
+		{
+			name: "sha256 fingerprint",
+			keys: []string{ssh.FingerprintSHA256(rsaHostKey)},
+		},
+		{
+			name: "sha256 fingerprint with padding",
+			keys: []string{ssh.FingerprintSHA256(rsaHostKey) + "="},
+		},
+		{
+			name: "legacy md5 fingerprint",
+			keys: []string{ssh.FingerprintLegacyMD5(rsaHostKey)},
+		},
+		{
+			name: "prefixed md5 fingerprint",
+			keys: []string{"MD5:" + strings.ToUpper(ssh.FingerprintLegacyMD5(rsaHostKey))},
+		},
+		{
+			name: "mixed keys and fingerprints",
+			keys: []string{ed25519Key, ssh.FingerprintSHA256(rsaHostKey)},
+		},
+		{
+			name:    "fingerprint mismatch",
+			keys:    []string{ssh.FingerprintSHA256(ed25519HostKey)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := sftp.NewMultiKeyCallback(tt.keys)
+			require.NoError(t, err)
+
+			err = callback("", nil, rsaHostKey)
+			if tt.wantErr {
+				require.ErrorContains(t, err, ssh.FingerprintSHA256(rsaHostKey))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMultiKeyCallback_InvalidFingerprint(t *testing.T) {
+	for _, fp := range []string{
+		"zz:bb:cc",
+		"SHA256:abc",
+		"SHA256:",
+		"SHA256:not base64 at all, not at all!!!!!!!!!!!!!!",
+		"SHA256:" + strings.Repeat("A", 42), // 31 bytes
+	} {
+		_, err := sftp.NewMultiKeyCallback([]string{fp})
+		require.Error(t, err, fp)
+	}
+}
+
+func TestClient_PinnedHostKeyAlgorithms(t *testing.T) {
+	// Setup a server offering both RSA and ed25519 host keys
+	server := sftptest.NewServer(t)
+
+	rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaSigner, err := ssh.NewSignerFromKey(rsaPrivateKey)
+	require.NoError(t, err)
+	server.Config.AddHostKey(rsaSigner)
+
+	tests := []struct {
+		name string
+		keys []string
+	}{
+		{
+			name: "ed25519",
+			keys: []string{server.HostKey()},
+		},
+		{
+			name: "rsa",
+			keys: []string{string(ssh.MarshalAuthorizedKey(rsaSigner.PublicKey()))},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+				Hostname:       server.Addr(),
+				Username:       sftptest.Username,
+				Password:       sftptest.Password,
+				Timeout:        5 * time.Second,
+				MaxConnections: 1,
+				HostPublicKeys: tt.keys,
+			})
+			require.NoError(t, err)
+			t.Cleanup(func() { client.Close() })
+
+			require.NoError(t, client.Ping())
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// setupIdleTimer creates the timer which closes idle connections, if IdleTimeout is set.
+func (c *client) setupIdleTimer() {
+	if c.cfg.IdleTimeout > 0 {
+		c.idleTimer = time.AfterFunc(c.cfg.IdleTimeout, c.closeIfIdle)
+		c.idleTimer.Stop()
+	}
+}
+
+// markUsed records activity on the connection and arms the idle timer if IdleTimeout is set.
+//
+// markUsed must be called within a mutex lock.
+func (c *client) markUsed() {
+	if c.cfg.IdleTimeout <= 0 {
+		return
+	}
+	c.lastUsed.Store(time.Now().UnixNano())
+	c.idleTimer.Reset(c.cfg.IdleTimeout)
+}
+
+// closeIfIdle closes the connection once no operation has run for IdleTimeout.
+// The next operation will reconnect.
+func (c *client) closeIfIdle() {
+	timeout := c.cfg.IdleTimeout
+
+	// An operation is running, so check again later
+	if !c.mu.TryLock() {
+		c.idleTimer.Reset(timeout)
+		return
+	}
+	defer c.mu.Unlock()
+
+	if c.client == nil {
+		return // already closed
+	}
+	idle := time.Since(time.Unix(0, c.lastUsed.Load()))
+	if idle < timeout {
+		c.idleTimer.Reset(timeout - idle)
+		return
+	}
+	if c.openReaders.Load() > 0 {
+		c.idleTimer.Reset(timeout)
+		return
+	}
+
+	if c.logger != nil {
+		c.logger.Logf("sftp: closing connection to %s after being idle for %v", c.cfg.Hostname, idle.Truncate(time.Millisecond))
+	}
+	c.metrics.idleClosed()
+
+	c.teardown(nil)
+}
+
+// idleTrackingReader keeps the connection open while a file returned by Reader is being read.
+type idleTrackingReader struct {
+	io.ReadCloser
+
+	client    *client
+	closeOnce sync.Once
+}
+
+func (c *client) trackReader(rc io.ReadCloser) io.ReadCloser {
+	if c.cfg.IdleTimeout <= 0 {
+		return rc
+	}
+	c.openReaders.Add(1)
+	return &idleTrackingReader{ReadCloser: rc, client: c}
+}
+
+func (r *idleTrackingReader) Read(p []byte) (int, error) {
+	r.client.lastUsed.Store(time.Now().UnixNano())
+	return r.ReadCloser.Read(p)
+}
+
+func (r *idleTrackingReader) Close() error {
+	r.closeOnce.Do(func() {
+		r.client.openReaders.Add(-1)
+	})
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_IdleTimeout(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.IdleTimeout = 50 * time.Millisecond
+	})
+	require.Equal(t, 1, server.Connections())
+
+	// Keep the connection busy
+	for i := 0; i < 5; i++ {
+		require.NoError(t, client.Delete("/missing.txt"))
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.Equal(t, 1, server.Connections())
+
+	// Let the connection sit idle, the next call reconnects
+	require.Eventually(t, func() bool {
+		return idleCloses(t, server.Addr()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, client.Delete("/missing.txt"))
+	require.Equal(t, 2, server.Connections())
+}
+
+func TestClient_CloseStalledOperation(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, nil)
+
+	// The operation blocks while holding the client's lock
+	server.StallConnections()
+	listed := make(chan error, 1)
+	go func() {
+		_, err := client.ListFiles("/")
+		listed <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- client.Close()
+	}()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close waited for the stalled operation")
+	}
+	<-listed // returned once its connection was closed
+}
+
+func TestClient_IdleTimeoutOpenReader(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.IdleTimeout = 50 * time.Millisecond
+	})
+	require.NoError(t, client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("hello"))))
+
+	file, err := client.Reader("/file.txt")
+	require.NoError(t, err)
+
+	// The connection stays open while the file is being read
+	time.Sleep(150 * time.Millisecond)
+	bs, err := io.ReadAll(file)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(bs))
+	require.NoError(t, file.Close())
+	require.Equal(t, 0, idleCloses(t, server.Addr()))
+
+	require.Eventually(t, func() bool {
+		return idleCloses(t, server.Addr()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func idleCloses(t *testing.T, hostname string) int {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "sftp_idle_closes" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "hostname" && label.GetValue() == hostname {
+					return int(metric.GetCounter().GetValue())
+				}
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,324 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+// Package sftptest provides an in-process SSH/SFTP server for tests.
+package sftptest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	Username = "demo"
+	Password = "password"
+)
+
+// Server is an SSH server offering the sftp subsystem backed by an in-memory filesystem.
+// Files persist across connections for the lifetime of the Server.
+type Server struct {
+	// Config is used for every incoming connection. It can be modified until Addr is called.
+	Config *ssh.ServerConfig
+
+	hostKey   ssh.Signer
+	listener  net.Listener
+	handlers  sftp.Handlers
+	startOnce sync.Once
+
+	mu       sync.Mutex
+	conns    []*conn
+	accepted int
+	faults   map[string][]error
+	requests map[string]int
+}
+
+// NewServer starts a Server which accepts Username and Password with an ed25519 host key.
+// The server is closed when t completes.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == Username && string(password) == Password {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	conf.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		Config:   conf,
+		hostKey:  signer,
+		listener: listener,
+		handlers: sftp.InMemHandler(),
+		faults:   make(map[string][]error),
+		requests: make(map[string]int),
+	}
+	t.Cleanup(func() {
+		s.Close()
+	})
+
+	return s
+}
+
+// Addr returns the host:port the server is listening on and starts accepting connections.
+func (s *Server) Addr() string {
+	s.startOnce.Do(func() {
+		go s.serve()
+	})
+	return s.listener.Addr().String()
+}
+
+// HostKey returns the server's ed25519 host key in authorized_keys format.
+func (s *Server) HostKey() string {
+	return string(ssh.MarshalAuthorizedKey(s.hostKey.PublicKey()))
+}
+
+// Close stops accepting connections and closes any open connections.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.DropConnections()
+	return err
+}
+
+// DropConnections closes the underlying network connection of every connected client
+// without any SSH disconnect message, as if the network failed.
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// StallConnections stops responding on every open connection without closing it,
+// as if the network silently dropped all packets. New connections are unaffected.
+func (s *Server) StallConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.stalled.Store(true)
+	}
+}
+
+// Connections returns how many connections have been accepted.
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accepted
+}
+
+// Requests returns how many SFTP requests for method have been received,
+// such as "Realpath", "Get", "Put", "List" or "Remove".
+func (s *Server) Requests(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[method]
+}
+
+// DropConnectionsOn drops every connection instead of answering the next SFTP request for method,
+// such as "Get", "Put", "List", "Stat", "Setstat", "Rename" or "Remove".
+func (s *Server) DropConnectionsOn(method string) {
+	s.FailOn(method, nil)
+}
+
+// FailOn answers the next SFTP request for method with err and leaves the connection open.
+// A nil err drops every connection like DropConnectionsOn.
+func (s *Server) FailOn(method string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults[method] = append(s.faults[method], err)
+}
+
+// fault returns the error requested for method, dropping every connection if needed.
+func (s *Server) fault(method string) error {
+	s.mu.Lock()
+	s.requests[method]++
+	faults := s.faults[method]
+	inject := len(faults) > 0
+	var err error
+	if inject {
+		err = faults[0]
+		s.faults[method] = faults[1:]
+	}
+	s.mu.Unlock()
+
+	if !inject {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.DropConnections()
+	return errors.New("connection dropped")
+}
+
+func (s *Server) serve() {
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := &conn{Conn: netConn, closed: make(chan struct{})}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.accepted++
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn *conn) {
+	defer conn.Close()
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.Config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(channel, requests)
+	}
+}
+
+func (s *Server) session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	for req := range requests {
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+
+		go ssh.DiscardRequests(requests)
+
+		handler := &faultHandler{server: s}
+		server := sftp.NewRequestServer(channel, sftp.Handlers{
+			FileGet:  handler,
+			FilePut:  handler,
+			FileCmd:  handler,
+			FileList: handler,
+		})
+		server.Serve()
+		server.Close()
+		return
+	}
+}
+
+// faultHandler injects faults before passing requests to the in-memory filesystem.
+type faultHandler struct {
+	server *Server
+}
+
+func (h *faultHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	if err := h.server.fault(r.Method); err != nil {
+		return nil, err
+	}
+	return h.server.handlers.FileGet.Fileread(r)
+}
+
+func (h *faultHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	if err := h.server.fault(r.Method); err != nil {
+		return nil, err
+	}
+	return h.server.handlers.FilePut.Filewrite(r)
+}
+
+func (h *faultHandler) Filecmd(r *sftp.Request) error {
+	if err := h.server.fault(r.Method); err != nil {
+		return err
+	}
+	return h.server.handlers.FileCmd.Filecmd(r)
+}
+
+func (h *faultHandler) PosixRename(r *sftp.Request) error {
+	if err := h.server.fault(r.Method); err != nil {
+		return err
+	}
+	return h.server.handlers.FileCmd.(sftp.PosixRenameFileCmder).PosixRename(r)
+}
+
+func (h *faultHandler) RealPath(p string) (string, error) {
+	if err := h.server.fault("Realpath"); err != nil {
+		return "", err
+	}
+	return path.Clean("/" + p), nil
+}
+
+func (h *faultHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	if err := h.server.fault(r.Method); err != nil {
+		return nil, err
+	}
+	return h.server.handlers.FileList.Filelist(r)
+}
+
+// conn blocks reads once stalled until it's closed.
+type conn struct {
+	net.Conn
+
+	stalled   atomic.Bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if c.stalled.Load() {
+		<-c.closed
+		return 0, net.ErrClosed
+	}
+	return n, err
+}
+
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultKeepaliveMaxMissed = 3
+
+// keepalive tracks the background keepalive requests for one SSH connection.
+type keepalive struct {
+	healthy atomic.Bool
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (k *keepalive) close() {
+	if k == nil {
+		return
+	}
+	k.healthy.Store(false)
+	k.stopOnce.Do(func() {
+		close(k.stop)
+	})
+}
+
+// startKeepalive begins sending keepalive@openssh.com requests over conn if KeepaliveInterval is set.
+//
+// startKeepalive must be called within a mutex lock.
+func (c *client) startKeepalive(conn *ssh.Client) {
+	c.keepalive.Swap(nil).close()
+
+	if c.cfg.KeepaliveInterval <= 0 {
+		return
+	}
+	k := &keepalive{
+		stop: make(chan struct{}),
+	}
+	k.healthy.Store(true)
+	c.keepalive.Store(k)
+
+	go c.runKeepalive(conn, k)
+}
+
+// keepaliveHealthy returns true if the current connection is answering keepalives.
+func (c *client) keepaliveHealthy() bool {
+	k := c.keepalive.Load()
+	return k != nil && k.healthy.Load()
+}
+
+func (c *client) runKeepalive(conn *ssh.Client, k *keepalive) {
+	interval := c.cfg.KeepaliveInterval
+	maxMissed := c.cfg.KeepaliveMaxMissed
+	if maxMissed <= 0 {
+		maxMissed = defaultKeepaliveMaxMissed
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var missed int
+	for missed < maxMissed {
+		select {
+		case <-k.stop:
+			return
+		case <-ticker.C:
+		}
+
+		// Any reply means the server is alive, OpenSSH answers with a failure
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+
+		select {
+		case <-k.stop:
+			return
+		case err := <-replied:
+			if err != nil {
+				missed = maxMissed // connection is closed
+			} else {
+				missed = 0
+			}
+		case <-time.After(interval):
+			missed++
+		}
+		k.healthy.Store(missed == 0)
+	}
+
+	// The connection is dead so tear it down before the next operation finds out
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != conn {
+		return // already replaced
+	}
+	if c.logger != nil {
+		c.logger.Warn().Logf("sftp: closing connection to %s after %d missed keepalives", c.cfg.Hostname, missed)
+	}
+	c.teardown(fmt.Errorf("sftp: %d missed keepalives", missed))
+
+	if c.cfg.KeepaliveReconnect {
+		_, err := c.connection(context.Background())
+		c.record(err)
+		if err != nil && c.logger != nil {
+			c.logger.Warn().Logf("sftp: reconnecting after missed keepalives: %v", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"testing"
+	"time"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_KeepaliveSkipsProbe(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.KeepaliveInterval = 50 * time.Millisecond
+	})
+
+	probes := server.Requests("Realpath")
+	for i := 0; i < 5; i++ {
+		require.NoError(t, client.Delete("/missing.txt"))
+	}
+	require.Equal(t, probes, server.Requests("Realpath"))
+
+	// Operations verify the connection without keepalives
+	server = sftptest.NewServer(t)
+	client = newTestClient(t, server, nil)
+
+	probes = server.Requests("Realpath")
+	for i := 0; i < 5; i++ {
+		require.NoError(t, client.Delete("/missing.txt"))
+	}
+	require.Equal(t, probes+5, server.Requests("Realpath"))
+}
+
+func TestClient_KeepaliveDeadConnection(t *testing.T) {
+	tests := []struct {
+		name      string
+		reconnect bool
+	}{
+		{name: "reconnect", reconnect: true},
+		{name: "lazy reconnect", reconnect: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := sftptest.NewServer(t)
+			client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+				cfg.KeepaliveInterval = 25 * time.Millisecond
+				cfg.KeepaliveMaxMissed = 2
+				cfg.KeepaliveReconnect = tt.reconnect
+			})
+			require.Equal(t, 1, server.Connections())
+
+			server.StallConnections()
+
+			if tt.reconnect {
+				require.Eventually(t, func() bool {
+					return server.Connections() == 2
+				}, 5*time.Second, 10*time.Millisecond)
+			} else {
+				time.Sleep(250 * time.Millisecond) // wait for keepalives to be missed
+				require.Equal(t, 1, server.Connections())
+			}
+
+			// The next operation doesn't hang on the stalled connection
+			require.NoError(t, client.Delete("/missing.txt"))
+			require.Equal(t, 2, server.Connections())
+		})
+	}
+}
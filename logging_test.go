@@ -0,0 +1,84 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_OperationLogging(t *testing.T) {
+	server := sftptest.NewServer(t)
+	buf, logger := log.NewBufferLogger()
+
+	client, err := sftp.NewClient(logger, &sftp.ClientConfig{
+		Hostname:       server.Addr(),
+		Username:       sftptest.Username,
+		Password:       sftptest.Password,
+		Timeout:        5 * time.Second,
+		MaxConnections: 1,
+		HostPublicKeys: []string{server.HostKey()},
+		OperationLogLevels: map[string]log.Level{
+			"upload": log.Info,
+		},
+		PathLogging: sftp.PathLoggingHash,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	buf.Reset()
+	require.NoError(t, client.UploadFile("/outbox/ssn-123-45-6789.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	line := buf.String()
+	require.Contains(t, line, "level=info")
+	require.Contains(t, line, "op=upload")
+	require.Contains(t, line, "bytes=8")
+	require.Contains(t, line, "attempt=1")
+	require.Contains(t, line, "hostname="+server.Addr())
+	require.Contains(t, line, "duration=")
+	require.Contains(t, line, "path=/outbox/")
+	require.NotContains(t, line, "ssn-123-45-6789")
+
+	buf.Reset()
+	_, err = client.ListFiles("/outbox")
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "level=debug")
+	require.Contains(t, buf.String(), "op=list")
+
+	// Failures are logged at error without the filename
+	buf.Reset()
+	_, err = client.Reader("/outbox/ssn-987-65-4321.txt")
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+
+	line = buf.String()
+	require.Contains(t, line, "level=error")
+	require.Contains(t, line, "op=read")
+	require.Contains(t, line, "error=")
+	require.NotContains(t, line, "ssn-987-65-4321")
+}
+
+func TestPathLogging(t *testing.T) {
+	_, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:    "localhost:22",
+		LazyConnect: true,
+		PathLogging: "scramble",
+	})
+	require.ErrorContains(t, err, "unknown PathLogging")
+
+	_, err = sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:           "localhost:22",
+		LazyConnect:        true,
+		OperationLogLevels: map[string]log.Level{"upload": "loud"},
+	})
+	require.ErrorContains(t, err, "unknown log level")
+}
@@ -0,0 +1,213 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics are the collectors for one client. MetricsLabels are variable labels, so clients
+// with different label names can share a Registerer. Clients with the same label names
+// share collectors and are told apart by their label values, including hostname.
+type metrics struct {
+	values []string // MetricsLabels values sorted by name, then hostname
+
+	*metricVecs
+}
+
+type metricVecs struct {
+	up                *prometheus.GaugeVec
+	connectionRetries *prometheus.CounterVec
+	idleCloses        *prometheus.CounterVec
+
+	operationDuration *prometheus.HistogramVec
+	operationErrors   *prometheus.CounterVec
+	uploadedBytes     *prometheus.CounterVec
+	downloadedBytes   *prometheus.CounterVec
+}
+
+var (
+	collectorsMu sync.Mutex
+	collectors   = make(map[prometheus.Registerer]*metricsCollector)
+)
+
+func newMetrics(cfg ClientConfig) (*metrics, error) {
+	reg := cfg.MetricsRegisterer
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	names := slices.Sorted(maps.Keys(cfg.MetricsLabels))
+
+	m := &metrics{}
+	for _, name := range names {
+		m.values = append(m.values, cfg.MetricsLabels[name])
+	}
+	m.values = append(m.values, cfg.Hostname)
+
+	collectorsMu.Lock()
+	defer collectorsMu.Unlock()
+
+	collector, ok := collectors[reg]
+	if !ok {
+		collector = &metricsCollector{vecs: make(map[string]*metricVecs)}
+		if err := reg.Register(collector); err != nil {
+			return nil, fmt.Errorf("registering metrics: %w", err)
+		}
+		collectors[reg] = collector
+	}
+
+	var err error
+	m.metricVecs, err = collector.forLabels(names)
+	if err != nil {
+		return nil, fmt.Errorf("registering metrics: %w", err)
+	}
+	return m, nil
+}
+
+// metricsCollector gathers the metrics from every client sharing a Registerer.
+//
+// It's an unchecked collector because label names vary between clients, which the
+// Registerer would reject from separately registered collectors with the same name.
+type metricsCollector struct {
+	mu   sync.Mutex
+	vecs map[string]*metricVecs // by comma separated label names
+}
+
+// Describe sends no descriptors, which registers the collector as unchecked.
+func (c *metricsCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, vecs := range c.vecs {
+		for _, collector := range vecs.collectors() {
+			collector.Collect(ch)
+		}
+	}
+}
+
+// forLabels returns the collectors for clients with the given MetricsLabels names.
+func (c *metricsCollector) forLabels(names []string) (*metricVecs, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := strings.Join(names, ",")
+	if vecs, ok := c.vecs[key]; ok {
+		return vecs, nil
+	}
+
+	labels := append(slices.Clip(names), "hostname")
+	vecs := &metricVecs{
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sftp_agent_up",
+			Help: "Status of SFTP agent connection",
+		}, labels),
+		connectionRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sftp_connection_retries",
+			Help: "Counter of SFTP connection retry attempts",
+		}, labels),
+		idleCloses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sftp_idle_closes",
+			Help: "Counter of SFTP connections closed after being idle",
+		}, labels),
+		operationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sftp_operation_duration_seconds",
+			Help:    "Histogram of SFTP operation latency in seconds",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, append(slices.Clip(labels), "op")),
+		operationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sftp_operation_errors",
+			Help: "Counter of failed SFTP operations by error type",
+		}, append(slices.Clip(labels), "op", "type")),
+		uploadedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sftp_uploaded_bytes",
+			Help: "Counter of bytes written to the SFTP server",
+		}, labels),
+		downloadedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sftp_downloaded_bytes",
+			Help: "Counter of bytes read from the SFTP server",
+		}, labels),
+	}
+
+	// Check label names are valid and don't conflict, which an unchecked collector would only find when gathering
+	check := prometheus.NewRegistry()
+	for _, collector := range vecs.collectors() {
+		if err := check.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	c.vecs[key] = vecs
+	return vecs, nil
+}
+
+func (v *metricVecs) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		v.up, v.connectionRetries, v.idleCloses,
+		v.operationDuration, v.operationErrors, v.uploadedBytes, v.downloadedBytes,
+	}
+}
+
+// with returns the label values for this client followed by extra.
+func (m *metrics) with(extra ...string) []string {
+	return append(slices.Clip(m.values), extra...)
+}
+
+func (m *metrics) setUp(up bool) {
+	if up {
+		m.up.WithLabelValues(m.values...).Set(1)
+	} else {
+		m.up.WithLabelValues(m.values...).Set(0)
+	}
+}
+
+func (m *metrics) connectionRetried(retries int) {
+	m.connectionRetries.WithLabelValues(m.values...).Add(float64(retries))
+}
+
+func (m *metrics) idleClosed() {
+	m.idleCloses.WithLabelValues(m.values...).Inc()
+}
+
+func (m *metrics) uploaded(n int64) {
+	m.uploadedBytes.WithLabelValues(m.values...).Add(float64(n))
+}
+
+func (m *metrics) observe(op string, took time.Duration, err error) {
+	m.operationDuration.WithLabelValues(m.with(op)...).Observe(took.Seconds())
+	if err != nil {
+		m.operationErrors.WithLabelValues(m.with(op, errorType(err))...).Inc()
+	}
+}
+
+// countingReader counts the bytes read from the server as they're consumed.
+type countingReader struct {
+	io.ReadCloser
+
+	count func(n int)
+}
+
+func (m *metrics) countDownload(rc io.ReadCloser) io.ReadCloser {
+	counter := m.downloadedBytes.WithLabelValues(m.values...)
+	return &countingReader{
+		ReadCloser: rc,
+		count:      func(n int) { counter.Add(float64(n)) },
+	}
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.count(n)
+	return n, err
+}
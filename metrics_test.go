@@ -0,0 +1,148 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/moov-io/base/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Metrics(t *testing.T) {
+	server := sftptest.NewServer(t)
+	registry := prometheus.NewRegistry()
+
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.MetricsRegisterer = registry
+		cfg.MetricsLabels = map[string]string{"client": "payroll", "username": sftptest.Username}
+	})
+	labels := map[string]string{"client": "payroll", "username": sftptest.Username, "hostname": server.Addr()}
+
+	require.NoError(t, client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	file, err := client.Reader("/file.txt")
+	require.NoError(t, err)
+	_, err = io.ReadAll(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	_, err = client.Reader("/missing.txt")
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+
+	families := gather(t, registry)
+	require.Equal(t, 1.0, findMetric(t, families, "sftp_agent_up", labels).GetGauge().GetValue())
+	require.Equal(t, 8.0, findMetric(t, families, "sftp_uploaded_bytes", labels).GetCounter().GetValue())
+	require.Equal(t, 8.0, findMetric(t, families, "sftp_downloaded_bytes", labels).GetCounter().GetValue())
+
+	labels["op"] = "upload"
+	require.Equal(t, uint64(1), findMetric(t, families, "sftp_operation_duration_seconds", labels).GetHistogram().GetSampleCount())
+	labels["op"] = "read"
+	require.Equal(t, uint64(2), findMetric(t, families, "sftp_operation_duration_seconds", labels).GetHistogram().GetSampleCount())
+
+	labels["type"] = "not_found"
+	require.Equal(t, 1.0, findMetric(t, families, "sftp_operation_errors", labels).GetCounter().GetValue())
+}
+
+func TestClient_MetricsSharedRegistry(t *testing.T) {
+	server := sftptest.NewServer(t)
+	registry := prometheus.NewRegistry()
+
+	// Clients to the same host are told apart by their labels
+	for _, name := range []string{"one", "two", "two"} {
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.MetricsRegisterer = registry
+			cfg.MetricsLabels = map[string]string{"client": name}
+		})
+		require.NoError(t, client.Ping())
+	}
+
+	families := gather(t, registry)
+	for _, name := range []string{"one", "two"} {
+		labels := map[string]string{"client": name, "hostname": server.Addr(), "op": "ping"}
+		count := findMetric(t, families, "sftp_operation_duration_seconds", labels).GetHistogram().GetSampleCount()
+		if name == "two" {
+			require.Equal(t, uint64(2), count)
+		} else {
+			require.Equal(t, uint64(1), count)
+		}
+	}
+
+	// Labels conflicting with the metric labels are rejected
+	_, err := sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:          server.Addr(),
+		Timeout:           time.Second,
+		MaxConnections:    1,
+		MetricsRegisterer: registry,
+		MetricsLabels:     map[string]string{"hostname": "other"},
+		LazyConnect:       true,
+	})
+	require.ErrorContains(t, err, "registering metrics")
+}
+
+func TestClient_MetricsMixedLabels(t *testing.T) {
+	server := sftptest.NewServer(t)
+	registry := prometheus.NewRegistry()
+
+	// Clients with and without labels can share a registry
+	for _, labels := range []map[string]string{nil, {"client": "b"}, {"client": "c", "team": "ops"}} {
+		client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+			cfg.MetricsRegisterer = registry
+			cfg.MetricsLabels = labels
+		})
+		require.NoError(t, client.Ping())
+	}
+
+	families := gather(t, registry)
+	for _, labels := range []map[string]string{
+		{"hostname": server.Addr()},
+		{"client": "b", "hostname": server.Addr()},
+		{"client": "c", "team": "ops", "hostname": server.Addr()},
+	} {
+		require.Equal(t, 1.0, findMetric(t, families, "sftp_agent_up", labels).GetGauge().GetValue())
+		labels["op"] = "ping"
+		require.Equal(t, uint64(1), findMetric(t, families, "sftp_operation_duration_seconds", labels).GetHistogram().GetSampleCount())
+	}
+}
+
+func gather(t *testing.T, gatherer prometheus.Gatherer) []*dto.MetricFamily {
+	t.Helper()
+
+	families, err := gatherer.Gather()
+	require.NoError(t, err)
+	return families
+}
+
+func findMetric(t *testing.T, families []*dto.MetricFamily, name string, labels map[string]string) *dto.Metric {
+	t.Helper()
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			if len(metric.GetLabel()) != len(labels) {
+				continue
+			}
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return metric
+		}
+	}
+	t.Fatalf("metric %s with labels %v not found", name, labels)
+	return nil
+}
@@ -5,6 +5,7 @@
 package go_sftp
 
 import (
+	"context"
 	"io"
 	"io/fs"
 	"os"
@@ -16,10 +17,21 @@ import (
 type MockClient struct {
 	root string
 
-	Err error
+	Err  error
+	Info ServerInfo
+	Caps Capabilities
+
+	// UploadTransforms and DownloadTransforms are run like ClientConfig's.
+	UploadTransforms   []Transform
+	DownloadTransforms []Transform
+
+	// Validators are run like ClientConfig's.
+	Validators []Validator
+
+	stats statsRecorder
 }
 
-var _ Client = (&MockClient{})
+var _ ContextClient = (&MockClient{})
 
 func NewMockClient(t *testing.T) *MockClient {
 	return &MockClient{
@@ -28,65 +40,125 @@ func NewMockClient(t *testing.T) *MockClient {
 }
 
 func (c *MockClient) Ping() error {
-	return c.Err
+	return c.stats.record("ping", newError("ping", "", c.Err))
 }
 
 func (c *MockClient) Dir() string {
 	return c.root
 }
 
+// ServerInfo returns the ServerInfo field
+func (c *MockClient) ServerInfo() ServerInfo {
+	return c.Info
+}
+
+// Capabilities returns the Caps field
+func (c *MockClient) Capabilities() (Capabilities, error) {
+	if c.Err != nil {
+		return Capabilities{}, newError("capabilities", "", c.Err)
+	}
+	return c.Caps, nil
+}
+
+// Stats returns counters of the operations called on the MockClient
+func (c *MockClient) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// ProbePermissions reports the local filesystem allows creating directories and changing permissions
+func (c *MockClient) ProbePermissions(dir string) (DirPermissions, error) {
+	if c.Err != nil {
+		return DirPermissions{}, newError("probe", dir, c.Err)
+	}
+	return DirPermissions{Mkdir: true, Chmod: true}, nil
+}
+
 func (c *MockClient) Close() error {
 	return c.Err
 }
 
 func (c *MockClient) Reader(path string) (*File, error) {
-	return c.Open(path)
+	file, err := c.open("read", path)
+	return file, c.stats.record("read", err)
 }
 
 func (c *MockClient) Open(path string) (*File, error) {
+	file, err := c.open("open", path)
+	return file, c.stats.record("open", err)
+}
+
+func (c *MockClient) open(op, path string) (*File, error) {
 	if c.Err != nil {
-		return nil, c.Err
+		return nil, newError(op, path, c.Err)
 	}
 	file, err := os.Open(filepath.Join(c.root, path))
 	if err != nil {
-		return nil, err
+		return nil, newError(op, path, err)
 	}
 	_, name := filepath.Split(path)
 	return &File{
 		Filename: name,
-		Contents: file,
+		Contents: transformDownload(c.stats.countDownload(file), c.DownloadTransforms),
 	}, nil
 }
 
 func (c *MockClient) Delete(path string) error {
-	return os.Remove(filepath.Join(c.root, path))
+	return c.stats.record("delete", newError("delete", path, os.Remove(filepath.Join(c.root, path))))
 }
 
 func (c *MockClient) UploadFile(path string, contents io.ReadCloser) error {
+	return c.stats.record("upload", c.uploadFile(path, contents))
+}
+
+func (c *MockClient) uploadFile(path string, contents io.ReadCloser) error {
 	if c.Err != nil {
-		return c.Err
+		return newError("upload", path, c.Err)
+	}
+
+	validated, err := validateBuffered(c.Validators, path, contents)
+	if err != nil {
+		return newError("upload", path, err)
 	}
 
 	dir, _ := filepath.Split(path)
 	if err := os.MkdirAll(filepath.Join(c.root, dir), 0777); err != nil {
-		return err
+		return newError("upload", path, err)
 	}
+	validating, finishValidation := validateStream(c.Validators, path, validated)
+	defer finishValidation(io.ErrClosedPipe)
 
-	bs, _ := io.ReadAll(contents)
+	stages := applyTransforms(validating, c.UploadTransforms)
+	bs, err := io.ReadAll(stages)
+	if err == nil {
+		err = stages.Close()
+	}
+	if err == nil {
+		err = finishValidation(nil)
+	}
+	if err != nil {
+		stages.Close()
+		return newError("upload", path, err)
+	}
+	c.stats.uploaded(int64(len(bs)))
 
-	return os.WriteFile(filepath.Join(c.root, path), bs, 0600)
+	return newError("upload", path, os.WriteFile(filepath.Join(c.root, path), bs, 0600))
 }
 
 func (c *MockClient) ListFiles(dir string) ([]string, error) {
+	files, err := c.listFiles(dir)
+	return files, c.stats.record("list", err)
+}
+
+func (c *MockClient) listFiles(dir string) ([]string, error) {
 	if c.Err != nil {
-		return nil, c.Err
+		return nil, newError("list", dir, c.Err)
 	}
 
 	os.MkdirAll(filepath.Join(c.root, dir), 0777)
 
 	fds, err := os.ReadDir(filepath.Join(c.root, dir))
 	if err != nil {
-		return nil, err
+		return nil, newError("list", dir, err)
 	}
 	var out []string
 	for i := range fds {
@@ -97,15 +169,69 @@ func (c *MockClient) ListFiles(dir string) ([]string, error) {
 }
 
 func (c *MockClient) Walk(dir string, fn fs.WalkDirFunc) error {
+	return c.stats.record("walk", c.walk(dir, fn))
+}
+
+func (c *MockClient) walk(dir string, fn fs.WalkDirFunc) error {
 	if c.Err != nil {
-		return c.Err
+		return newError("walk", dir, c.Err)
 	}
 
 	d, err := filepath.Abs(filepath.Join(c.root, dir))
 	if err != nil {
-		return err
+		return newError("walk", dir, err)
 	}
 	os.MkdirAll(d, 0777)
 
 	return fs.WalkDir(os.DirFS(d), ".", fn)
 }
+
+func (c *MockClient) PingContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return c.stats.record("ping", newError("ping", "", err))
+	}
+	return c.Ping()
+}
+
+func (c *MockClient) OpenContext(ctx context.Context, path string) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, c.stats.record("open", newError("open", path, err))
+	}
+	return c.Open(path)
+}
+
+func (c *MockClient) ReaderContext(ctx context.Context, path string) (*File, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, c.stats.record("read", newError("read", path, err))
+	}
+	return c.Reader(path)
+}
+
+func (c *MockClient) DeleteContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return c.stats.record("delete", newError("delete", path, err))
+	}
+	return c.Delete(path)
+}
+
+func (c *MockClient) UploadFileContext(ctx context.Context, path string, contents io.ReadCloser) error {
+	if err := ctx.Err(); err != nil {
+		contents.Close()
+		return c.stats.record("upload", newError("upload", path, err))
+	}
+	return c.UploadFile(path, contents)
+}
+
+func (c *MockClient) ListFilesContext(ctx context.Context, dir string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, c.stats.record("list", newError("list", dir, err))
+	}
+	return c.ListFiles(dir)
+}
+
+func (c *MockClient) WalkContext(ctx context.Context, dir string, fn fs.WalkDirFunc) error {
+	if err := ctx.Err(); err != nil {
+		return c.stats.record("walk", newError("walk", dir, err))
+	}
+	return c.Walk(dir, fn)
+}
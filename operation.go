@@ -0,0 +1,164 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moov-io/base/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// operation tracks one call of a Client method from start to finish.
+type operation struct {
+	client *client
+
+	name  string // such as "upload" or "list"
+	path  string
+	start time.Time
+	span  trace.Span
+
+	attempts int
+	bytes    int64
+	sha256   string // hex encoded, only computed when auditing
+}
+
+type operationKey struct{}
+
+// startOperation begins the span and timing for a Client method.
+// The operation is carried in the returned context.
+func (c *client) startOperation(ctx context.Context, name, path string) (context.Context, *operation) {
+	ctx, span := c.startSpan(ctx, name, attribute.String("sftp.path", path))
+	c.stats.started()
+	op := &operation{
+		client: c,
+		name:   name,
+		path:   path,
+		start:  time.Now(),
+		span:   span,
+	}
+	return context.WithValue(ctx, operationKey{}, op), op
+}
+
+// operationFrom returns the operation started for ctx, if any.
+func operationFrom(ctx context.Context) *operation {
+	op, _ := ctx.Value(operationKey{}).(*operation)
+	return op
+}
+
+// finish records metrics, logs and ends the span for the operation, wrapping any error in an *Error.
+func (o *operation) finish(err error) error {
+	took := time.Since(o.start)
+
+	o.client.metrics.observe(o.name, took, err)
+	o.client.logOperation(o, took, err)
+	o.audit(err)
+	endSpan(o.span, err)
+
+	err = newError(o.name, o.path, err)
+	o.client.stats.finished(o.name, err)
+	return err
+}
+
+// PathLogging controls how file paths are written to logs.
+type PathLogging string
+
+const (
+	// PathLoggingPlain logs paths unchanged.
+	PathLoggingPlain PathLogging = ""
+
+	// PathLoggingRedact replaces filenames with "[redacted]" and keeps their directory.
+	PathLoggingRedact PathLogging = "redact"
+
+	// PathLoggingHash replaces filenames with a truncated SHA-256 hash and keeps their directory,
+	// so the same file can be followed across log lines.
+	PathLoggingHash PathLogging = "hash"
+)
+
+func (mode PathLogging) validate() error {
+	switch mode {
+	case PathLoggingPlain, PathLoggingRedact, PathLoggingHash:
+		return nil
+	}
+	return fmt.Errorf("unknown PathLogging %q", mode)
+}
+
+// hide returns path with its filename redacted or hashed.
+func (mode PathLogging) hide(path string) string {
+	dir, name := filepath.Split(path)
+	if name == "" {
+		return path
+	}
+	switch mode {
+	case PathLoggingRedact:
+		return dir + "[redacted]"
+	case PathLoggingHash:
+		sum := sha256.Sum256([]byte(name))
+		return dir + hex.EncodeToString(sum[:8])
+	}
+	return path
+}
+
+func checkLogLevels(levels map[string]log.Level) error {
+	for op, level := range levels {
+		switch level {
+		case log.Debug, log.Info, log.Warn, log.Error:
+		default:
+			return fmt.Errorf("unknown log level %q for %s", level, op)
+		}
+	}
+	return nil
+}
+
+// logOperation writes a structured log line once an operation completes.
+// Successful operations are logged at the level from OperationLogLevels and failures at error.
+func (c *client) logOperation(op *operation, took time.Duration, err error) {
+	if c.logger == nil {
+		return
+	}
+
+	path := op.path
+	if c.cfg.PathLogging != PathLoggingPlain {
+		path = c.cfg.PathLogging.hide(op.path)
+	}
+	fields := log.Fields{
+		"op":       log.String(op.name),
+		"path":     log.String(path),
+		"duration": log.TimeDuration(took),
+		"attempt":  log.Int(max(op.attempts, 1)),
+		"hostname": log.String(c.cfg.Hostname),
+	}
+	if op.bytes > 0 {
+		fields["bytes"] = log.Int64(op.bytes)
+	}
+
+	level := log.Debug
+	if l, ok := c.cfg.OperationLogLevels[op.name]; ok {
+		level = l
+	}
+	if err != nil {
+		level = log.Error
+
+		msg := err.Error()
+		if c.cfg.PathLogging != PathLoggingPlain && op.path != "" {
+			// Errors often include the path or filename
+			msg = strings.ReplaceAll(msg, op.path, path)
+			if _, name := filepath.Split(op.path); name != "" {
+				_, hidden := filepath.Split(path)
+				msg = strings.ReplaceAll(msg, name, hidden)
+			}
+		}
+		fields["error"] = log.String(msg)
+	}
+
+	c.logger.With(level, fields).Logf("sftp: %s", op.name)
+}
@@ -0,0 +1,394 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+)
+
+// PGPConfig encrypts files with OpenPGP as they're uploaded and decrypts them as they're read.
+// Keys can be ASCII armored or binary.
+type PGPConfig struct {
+	// RecipientPublicKeys encrypt uploaded files. Uploads are not encrypted when empty.
+	RecipientPublicKeys []string
+
+	// SigningKey is an optional private key which signs encrypted uploads, or makes
+	// detached signatures when DetachedSignatures is set.
+	SigningKey           string
+	SigningKeyPassphrase string
+
+	// Armor writes encrypted uploads and detached signatures ASCII armored rather than binary.
+	Armor bool
+
+	// DetachedSignatures uploads a signature of each file, as written to the server,
+	// at the file's path plus SignatureSuffix. Requires SigningKey.
+	DetachedSignatures bool
+
+	// SignatureSuffix is appended to paths for detached signatures. Defaults to ".sig".
+	SignatureSuffix string
+
+	// PrivateKey decrypts files read from the server. Files are read unchanged when empty.
+	// Both armored and binary messages are accepted.
+	PrivateKey           string
+	PrivateKeyPassphrase string
+
+	// VerifyPublicKeys are signers trusted for files which are read. When set, decrypted files
+	// must be signed by one of these keys and an invalid signature fails once the contents are
+	// fully read.
+	VerifyPublicKeys []string
+
+	// VerifyDetachedSignatures reads the detached signature at each file's path plus SignatureSuffix
+	// and checks it against VerifyPublicKeys as the file is read, instead of signatures within
+	// encrypted files. Reading the last of the contents fails with ErrInvalidSignature when the
+	// signature doesn't match.
+	VerifyDetachedSignatures bool
+}
+
+// maxSignatureSize limits how much of a detached signature file is read.
+const maxSignatureSize = 64 * 1024
+
+// pgpCodec holds the keys parsed from a PGPConfig.
+type pgpCodec struct {
+	armor bool
+
+	recipients openpgp.EntityList
+	signer     *openpgp.Entity
+	detached   bool
+	suffix     string
+
+	hasPrivateKey  bool
+	keyring        openpgp.EntityList // private keys and trusted signers
+	trusted        openpgp.EntityList
+	verifyInline   bool
+	verifyDetached bool
+}
+
+func newPGPCodec(cfg *PGPConfig) (*pgpCodec, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	codec := &pgpCodec{
+		armor:          cfg.Armor,
+		detached:       cfg.DetachedSignatures,
+		suffix:         cfg.SignatureSuffix,
+		verifyDetached: cfg.VerifyDetachedSignatures,
+	}
+	if codec.suffix == "" {
+		codec.suffix = ".sig"
+	}
+
+	for i, key := range cfg.RecipientPublicKeys {
+		entities, err := readPGPKeys(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading PGP recipient key #%d: %w", i, err)
+		}
+		codec.recipients = append(codec.recipients, entities...)
+	}
+
+	if cfg.SigningKey != "" {
+		entities, err := readPGPPrivateKeys(cfg.SigningKey, cfg.SigningKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("reading PGP signing key: %w", err)
+		}
+		codec.signer = entities[0]
+	}
+
+	if cfg.PrivateKey != "" {
+		entities, err := readPGPPrivateKeys(cfg.PrivateKey, cfg.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("reading PGP private key: %w", err)
+		}
+		codec.keyring = append(codec.keyring, entities...)
+		codec.hasPrivateKey = true
+	}
+
+	for i, key := range cfg.VerifyPublicKeys {
+		entities, err := readPGPKeys(key)
+		if err != nil {
+			return nil, fmt.Errorf("reading PGP verify key #%d: %w", i, err)
+		}
+		codec.trusted = append(codec.trusted, entities...)
+	}
+	codec.keyring = append(codec.keyring, codec.trusted...)
+
+	switch {
+	case codec.detached && codec.signer == nil:
+		return nil, errors.New("PGP DetachedSignatures requires a SigningKey")
+	case codec.verifyDetached && len(codec.trusted) == 0:
+		return nil, errors.New("PGP VerifyDetachedSignatures requires VerifyPublicKeys")
+	case len(codec.trusted) > 0 && !codec.verifyDetached:
+		if !codec.hasPrivateKey {
+			return nil, errors.New("PGP VerifyPublicKeys requires a PrivateKey or VerifyDetachedSignatures")
+		}
+		codec.verifyInline = true
+	}
+
+	return codec, nil
+}
+
+func readPGPKeys(key string) (openpgp.EntityList, error) {
+	var entities openpgp.EntityList
+	var err error
+	if isArmored([]byte(key)) {
+		entities, err = openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	} else {
+		entities, err = openpgp.ReadKeyRing(strings.NewReader(key))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("no keys found")
+	}
+	return entities, nil
+}
+
+func readPGPPrivateKeys(key, passphrase string) (openpgp.EntityList, error) {
+	entities, err := readPGPKeys(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			return nil, errors.New("not a private key")
+		}
+		if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("decrypting private key: %w", err)
+		}
+	}
+	return entities, nil
+}
+
+func isArmored(bs []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(bs, " \t\r\n"), []byte("-----BEGIN PGP"))
+}
+
+// encrypts reports if uploads are encrypted.
+func (p *pgpCodec) encrypts() bool {
+	return p != nil && len(p.recipients) > 0
+}
+
+// decrypts reports if downloads are decrypted.
+func (p *pgpCodec) decrypts() bool {
+	return p != nil && p.hasPrivateKey
+}
+
+// signsDetached reports if uploads have a detached signature uploaded next to them.
+func (p *pgpCodec) signsDetached() bool {
+	return p != nil && p.detached
+}
+
+// verifiesDetached reports if downloads are checked against a detached signature.
+func (p *pgpCodec) verifiesDetached() bool {
+	return p != nil && p.verifyDetached
+}
+
+func (p *pgpCodec) signaturePath(path string) string {
+	return path + p.suffix
+}
+
+// encrypt returns a reader of the encrypted contents of r.
+// Encryption happens as the returned reader is consumed.
+func (p *pgpCodec) encrypt(r io.Reader) io.ReadCloser {
+	return pipeReader(func(w io.Writer) error {
+		out := w
+		var armored io.WriteCloser
+		if p.armor {
+			var err error
+			armored, err = armor.Encode(w, "PGP MESSAGE", nil)
+			if err != nil {
+				return err
+			}
+			out = armored
+		}
+
+		signer := p.signer
+		if p.detached {
+			signer = nil
+		}
+		plaintext, err := openpgp.Encrypt(out, p.recipients, signer, nil, nil)
+		if err != nil {
+			return fmt.Errorf("pgp: encrypting: %w", err)
+		}
+		if _, err := io.Copy(plaintext, r); err != nil {
+			return err
+		}
+		if err := plaintext.Close(); err != nil {
+			return fmt.Errorf("pgp: encrypting: %w", err)
+		}
+		if armored != nil {
+			return armored.Close()
+		}
+		return nil
+	})
+}
+
+// decrypt returns a reader of the decrypted contents of rc, which is closed with the returned reader.
+// The message headers are read immediately so a missing key fails before any contents are read.
+func (p *pgpCodec) decrypt(rc io.ReadCloser) (io.ReadCloser, error) {
+	buf := bufio.NewReader(rc)
+
+	var src io.Reader = buf
+	peek, _ := buf.Peek(64)
+	if isArmored(peek) {
+		block, err := armor.Decode(buf)
+		if err != nil {
+			return nil, fmt.Errorf("pgp: decoding armor: %w", err)
+		}
+		src = block.Body
+	}
+
+	md, err := openpgp.ReadMessage(src, p.keyring, nil, nil)
+	if err != nil {
+		if errors.Is(err, pgperrors.ErrKeyIncorrect) {
+			return nil, fmt.Errorf("pgp: no private key for message: %w", err)
+		}
+		return nil, fmt.Errorf("pgp: reading message: %w", err)
+	}
+	if p.verifyInline && md.SignedBy == nil {
+		return nil, errors.New("pgp: message is not signed by a trusted key")
+	}
+
+	return &decryptingReader{md: md, closer: rc}, nil
+}
+
+type decryptingReader struct {
+	md     *openpgp.MessageDetails
+	closer io.Closer
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	n, err := r.md.UnverifiedBody.Read(p)
+	if err == io.EOF && r.md.SignedBy != nil && r.md.SignatureError != nil {
+		return n, fmt.Errorf("pgp: invalid signature: %w", r.md.SignatureError)
+	}
+	return n, err
+}
+
+func (r *decryptingReader) Close() error {
+	return r.closer.Close()
+}
+
+// signDetached returns a writer which computes a detached signature of everything written to it.
+// Signing happens as data is written, so the whole file is never held in memory.
+func (p *pgpCodec) signDetached() *signatureWriter {
+	pr, pw := io.Pipe()
+	s := &signatureWriter{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		if p.armor {
+			s.err = openpgp.ArmoredDetachSign(&s.sig, p.signer, pr, nil)
+		} else {
+			s.err = openpgp.DetachSign(&s.sig, p.signer, pr, nil)
+		}
+		pr.CloseWithError(s.err) // stop any writes if signing failed
+	}()
+	return s
+}
+
+type signatureWriter struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+
+	sig bytes.Buffer
+	err error
+}
+
+func (s *signatureWriter) Write(p []byte) (int, error) {
+	return s.pw.Write(p)
+}
+
+// finish returns the signature of everything written, or cancels signing when err is non-nil.
+func (s *signatureWriter) finish(err error) ([]byte, error) {
+	s.pw.CloseWithError(err)
+	<-s.done
+	if s.err != nil {
+		return nil, fmt.Errorf("pgp: signing: %w", s.err)
+	}
+	return s.sig.Bytes(), nil
+}
+
+// checkDetached returns a reader of rc which checks signature against the trusted keys as it's read.
+// Reading the end of rc returns ErrInvalidSignature instead of io.EOF if the signature doesn't match.
+func (p *pgpCodec) checkDetached(rc io.ReadCloser, signature []byte) io.ReadCloser {
+	pr, pw := io.Pipe()
+	v := &verifyingReader{ReadCloser: rc, pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(v.done)
+		var err error
+		if isArmored(signature) {
+			_, err = openpgp.CheckArmoredDetachedSignature(p.trusted, pr, bytes.NewReader(signature), nil)
+		} else {
+			_, err = openpgp.CheckDetachedSignature(p.trusted, pr, bytes.NewReader(signature), nil)
+		}
+		v.err = err
+		pr.CloseWithError(err)
+	}()
+	return v
+}
+
+type verifyingReader struct {
+	io.ReadCloser
+
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if _, werr := r.pw.Write(p[:n]); werr != nil {
+			<-r.done
+			return n, fmt.Errorf("%w: %v", ErrInvalidSignature, r.err)
+		}
+	}
+	if err == io.EOF {
+		r.pw.Close()
+		<-r.done
+		if r.err != nil {
+			return n, fmt.Errorf("%w: %v", ErrInvalidSignature, r.err)
+		}
+	}
+	return n, err
+}
+
+func (r *verifyingReader) Close() error {
+	r.pw.CloseWithError(io.ErrClosedPipe)
+	<-r.done
+	return r.ReadCloser.Close()
+}
+
+// pipeReader runs write in a goroutine and returns a reader of what it writes.
+// Closing the reader stops write and waits for it to return.
+func pipeReader(write func(w io.Writer) error) io.ReadCloser {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pw.CloseWithError(write(pw))
+	}()
+	return &pipeReadCloser{PipeReader: pr, done: done}
+}
+
+type pipeReadCloser struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+func (r *pipeReadCloser) Close() error {
+	r.PipeReader.CloseWithError(io.ErrClosedPipe)
+	<-r.done
+	return nil
+}
@@ -0,0 +1,294 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_PGP(t *testing.T) {
+	server := sftptest.NewServer(t)
+	plain := newTestClient(t, server, nil)
+
+	partner := newPGPKeys(t, "")
+	ours := newPGPKeys(t, "secret")
+
+	for _, armored := range []bool{true, false} {
+		name := "binary"
+		if armored {
+			name = "armored"
+		}
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+				cfg.PGP = &sftp.PGPConfig{
+					RecipientPublicKeys:  []string{partner.public},
+					SigningKey:           ours.private,
+					SigningKeyPassphrase: "secret",
+					Armor:                armored,
+					PrivateKey:           partner.private,
+					VerifyPublicKeys:     []string{ours.public},
+				}
+			})
+
+			err := client.UploadFile("/outbox/file.txt", io.NopCloser(strings.NewReader("contents")))
+			require.NoError(t, err)
+
+			// The server only has the encrypted file
+			encrypted := readAll(t, plain, "/outbox/file.txt")
+			require.NotContains(t, encrypted, "contents")
+			require.Equal(t, armored, strings.HasPrefix(encrypted, "-----BEGIN PGP MESSAGE-----"))
+
+			require.Equal(t, "contents", readAll(t, client, "/outbox/file.txt"))
+
+			file, err := client.Open("/outbox/file.txt")
+			require.NoError(t, err)
+			bs, err := io.ReadAll(file.Contents)
+			require.NoError(t, err)
+			require.Equal(t, "contents", string(bs))
+		})
+	}
+
+	// Files can't be read without the recipient's private key
+	stranger := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.PGP = &sftp.PGPConfig{
+			PrivateKey: newPGPKeys(t, "").private,
+		}
+	})
+	_, err := stranger.Reader("/outbox/file.txt")
+	require.ErrorContains(t, err, "pgp: no private key for message")
+
+	// Files must be signed by a trusted key
+	untrusted := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.PGP = &sftp.PGPConfig{
+			PrivateKey:       partner.private,
+			VerifyPublicKeys: []string{partner.public},
+		}
+	})
+	_, err = untrusted.Reader("/outbox/file.txt")
+	require.ErrorContains(t, err, "not signed by a trusted key")
+}
+
+func TestClient_PGPTruncated(t *testing.T) {
+	server := sftptest.NewServer(t)
+	plain := newTestClient(t, server, nil)
+
+	keys := newPGPKeys(t, "")
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.OperationRetryPolicy = testOperationRetryPolicy
+		cfg.PGP = &sftp.PGPConfig{
+			RecipientPublicKeys: []string{keys.public},
+			PrivateKey:          keys.private,
+		}
+	})
+	require.NoError(t, client.UploadFile("/full.pgp", io.NopCloser(strings.NewReader(strings.Repeat("contents", 1000)))))
+	encrypted := readAll(t, plain, "/full.pgp")
+
+	files := map[string]string{
+		"/empty.pgp":     "",
+		"/one-byte.pgp":  encrypted[:1],
+		"/truncated.pgp": encrypted[:len(encrypted)/2],
+	}
+	for path, contents := range files {
+		require.NoError(t, plain.UploadFile(path, io.NopCloser(strings.NewReader(contents))))
+	}
+
+	// Malformed messages fail without being mistaken for the connection being lost
+	for path := range files {
+		gets := server.Requests("Get")
+		_, err := client.Open(path)
+		require.Error(t, err, path)
+		require.NotErrorIs(t, err, sftp.ErrConnectionLost, path)
+		require.Equal(t, gets+1, server.Requests("Get"), path)
+	}
+	require.Equal(t, 2, server.Connections())
+}
+
+func TestClient_PGPDetachedSignatures(t *testing.T) {
+	server := sftptest.NewServer(t)
+	plain := newTestClient(t, server, nil)
+	ours := newPGPKeys(t, "")
+
+	signer := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.AtomicUploads = true
+		cfg.PGP = &sftp.PGPConfig{
+			SigningKey:         ours.private,
+			DetachedSignatures: true,
+		}
+	})
+	err := signer.UploadFile("/outbox/file.txt", io.NopCloser(strings.NewReader("contents")))
+	require.NoError(t, err)
+	require.Equal(t, "contents", readAll(t, plain, "/outbox/file.txt"))
+
+	files, err := plain.ListFiles("/outbox")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"/outbox/file.txt", "/outbox/file.txt.sig"}, files)
+
+	verifier := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.PGP = &sftp.PGPConfig{
+			VerifyPublicKeys:         []string{ours.public},
+			VerifyDetachedSignatures: true,
+		}
+	})
+	require.Equal(t, "contents", readAll(t, verifier, "/outbox/file.txt"))
+
+	// Armored signatures with a custom suffix
+	armored := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.PGP = &sftp.PGPConfig{
+			SigningKey:               ours.private,
+			Armor:                    true,
+			DetachedSignatures:       true,
+			SignatureSuffix:          ".asc",
+			VerifyPublicKeys:         []string{ours.public},
+			VerifyDetachedSignatures: true,
+		}
+	})
+	err = armored.UploadFile("/outbox/other.txt", io.NopCloser(strings.NewReader("other")))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(readAll(t, plain, "/outbox/other.txt.asc"), "-----BEGIN PGP SIGNATURE-----"))
+	require.Equal(t, "other", readAll(t, armored, "/outbox/other.txt"))
+
+	// Modified files fail verification once read
+	err = plain.UploadFile("/outbox/file.txt", io.NopCloser(strings.NewReader("modified")))
+	require.NoError(t, err)
+
+	file, err := verifier.Reader("/outbox/file.txt")
+	require.NoError(t, err)
+	_, err = io.ReadAll(file.Contents)
+	require.ErrorIs(t, err, sftp.ErrInvalidSignature)
+	require.NoError(t, file.Close())
+
+	_, err = verifier.Open("/outbox/file.txt")
+	require.ErrorIs(t, err, sftp.ErrInvalidSignature)
+
+	// Files without a signature can't be read
+	err = plain.UploadFile("/outbox/unsigned.txt", io.NopCloser(strings.NewReader("unsigned")))
+	require.NoError(t, err)
+	_, err = verifier.Reader("/outbox/unsigned.txt")
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+	require.ErrorContains(t, err, "reading signature /outbox/unsigned.txt.sig")
+}
+
+func TestClient_PGPDetachedSignaturesFailedUpload(t *testing.T) {
+	server := sftptest.NewServer(t)
+	keys := newPGPKeys(t, "")
+
+	stage := &countingStage{err: errors.New("stage failed")}
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.UploadTransforms = []sftp.Transform{stage.wrap}
+		cfg.PGP = &sftp.PGPConfig{
+			SigningKey:         keys.private,
+			DetachedSignatures: true,
+		}
+	})
+	require.NoError(t, client.Ping())
+
+	// Signing stops when an upload fails after its contents are copied
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		err := client.UploadFile(fmt.Sprintf("/outbox/%d.txt", i), io.NopCloser(strings.NewReader("contents")))
+		require.ErrorContains(t, err, "stage failed")
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestClient_PGPConfig(t *testing.T) {
+	server := sftptest.NewServer(t)
+	keys := newPGPKeys(t, "secret")
+
+	tests := []struct {
+		name     string
+		cfg      sftp.PGPConfig
+		expected string
+	}{
+		{
+			name:     "invalid recipient",
+			cfg:      sftp.PGPConfig{RecipientPublicKeys: []string{"not a key"}},
+			expected: "reading PGP recipient key #0",
+		},
+		{
+			name:     "wrong passphrase",
+			cfg:      sftp.PGPConfig{PrivateKey: keys.private, PrivateKeyPassphrase: "wrong"},
+			expected: "decrypting private key",
+		},
+		{
+			name:     "detached signatures without signing key",
+			cfg:      sftp.PGPConfig{DetachedSignatures: true},
+			expected: "requires a SigningKey",
+		},
+		{
+			name:     "verify detached signatures without keys",
+			cfg:      sftp.PGPConfig{VerifyDetachedSignatures: true},
+			expected: "requires VerifyPublicKeys",
+		},
+		{
+			name:     "public key for decrypting",
+			cfg:      sftp.PGPConfig{PrivateKey: keys.public},
+			expected: "not a private key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sftp.NewClient(nil, &sftp.ClientConfig{
+				Hostname:    server.Addr(),
+				LazyConnect: true,
+				PGP:         &tt.cfg,
+			})
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+type pgpKeys struct {
+	public, private string
+}
+
+func newPGPKeys(t *testing.T, passphrase string) pgpKeys {
+	t.Helper()
+
+	entity, err := openpgp.NewEntity("moov", "", "test@moov.io", nil)
+	require.NoError(t, err)
+
+	var public bytes.Buffer
+	w, err := armor.Encode(&public, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+
+	// The private key is binary to cover both formats
+	var private bytes.Buffer
+	if passphrase != "" {
+		require.NoError(t, entity.EncryptPrivateKeys([]byte(passphrase), nil))
+		require.NoError(t, entity.SerializePrivateWithoutSigning(&private, nil))
+	} else {
+		require.NoError(t, entity.SerializePrivate(&private, nil))
+	}
+
+	return pgpKeys{public: public.String(), private: private.String()}
+}
+
+func readAll(t *testing.T, client sftp.Client, path string) string {
+	t.Helper()
+
+	file, err := client.Reader(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	bs, err := io.ReadAll(file.Contents)
+	require.NoError(t, err)
+	return string(bs)
+}
@@ -0,0 +1,164 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/sftp"
+)
+
+// isConnectionLost returns true for errors which mean the SSH/SFTP connection is no longer usable,
+// including errors marked by ClientConfig.IsConnectionError.
+func (c *client) isConnectionLost(err error) bool {
+	if err == nil || !fromConnection(err) {
+		return false
+	}
+	if isConnectionLost(err) {
+		return true
+	}
+	return c.cfg.IsConnectionError != nil && c.cfg.IsConnectionError(err)
+}
+
+func isConnectionLost(err error) bool {
+	if !fromConnection(err) {
+		return false
+	}
+
+	// Servers return generic failures for many problems, so reconnect in case the connection is broken
+	if errors.Is(err, sftp.ErrSSHFxFailure) {
+		return true
+	}
+	return isConnectionClosed(err)
+}
+
+// isConnectionClosed returns true for errors which show the connection was closed or broken,
+// which is narrower than isConnectionLost and used to match ErrConnectionLost.
+func isConnectionClosed(err error) bool {
+	if err == nil || !fromConnection(err) {
+		return false
+	}
+
+	// Possible errors from github.com/pkg/sftp/request-errors.go
+	switch {
+	case errors.Is(err, sftp.ErrSSHFxEOF),
+		errors.Is(err, sftp.ErrSSHFxBadMessage),
+		errors.Is(err, sftp.ErrSSHFxNoConnection),
+		errors.Is(err, sftp.ErrSSHFxConnectionLost):
+		return true
+	}
+
+	// The underlying network connection or SSH transport failed
+	switch {
+	case errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, io.ErrClosedPipe),
+		errors.Is(err, net.ErrClosed),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+
+	// golang.org/x/crypto/ssh doesn't export its disconnect error
+	return strings.Contains(err.Error(), "ssh: disconnect")
+}
+
+// fromConnection returns false for errors which didn't come from the SSH/SFTP connection,
+// such as reading the contents of an upload, decoding a download, failed validation or a cancelled context,
+// even when they wrap errors like io.ErrUnexpectedEOF.
+func fromConnection(err error) bool {
+	switch {
+	case errors.As(err, new(*sourceError)),
+		errors.As(err, new(*decodeError)),
+		errors.As(err, new(*ValidationError)),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	return true
+}
+
+// sourceError wraps errors from reading the contents of an upload, which includes
+// Validators, Transforms, compression and encryption.
+type sourceError struct {
+	err error
+}
+
+func (e *sourceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
+
+// sourceReader marks errors from r as a *sourceError so they're told apart from errors writing to the server.
+type sourceReader struct {
+	r io.Reader
+}
+
+func (r sourceReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = &sourceError{err: err}
+	}
+	return n, err
+}
+
+// decodeError wraps errors from decoding the contents of a download, such as decrypting or
+// decompressing it, which come from the file rather than the connection.
+type decodeError struct {
+	err error
+}
+
+func (e *decodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *decodeError) Unwrap() error {
+	return e.err
+}
+
+// rawReader records errors reading a download from the server, so errors from decoding
+// it can be told apart from the connection failing underneath the decoder.
+type rawReader struct {
+	io.ReadCloser
+
+	err error
+}
+
+func (r *rawReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// decoded marks err as a *decodeError unless reading from the server failed.
+func (r *rawReader) decoded(err error) error {
+	if err == nil || err == io.EOF || r.err != nil || errors.As(err, new(*decodeError)) {
+		return err
+	}
+	return &decodeError{err: err}
+}
+
+// decodingReader marks errors reading decoded contents with raw.decoded.
+type decodingReader struct {
+	io.ReadCloser
+
+	raw *rawReader
+}
+
+func (r decodingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	return n, r.raw.decoded(err)
+}
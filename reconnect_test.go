@@ -0,0 +1,69 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsConnectionLost(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{err: nil, expected: false},
+		{err: os.ErrNotExist, expected: false},
+		{err: os.ErrPermission, expected: false},
+		{err: errors.New("quota exceeded"), expected: false},
+		{err: sftp.ErrSSHFxFailure, expected: true},
+		{err: sftp.ErrSSHFxConnectionLost, expected: true},
+		{err: sftp.ErrSSHFxNoConnection, expected: true},
+		{err: io.EOF, expected: true},
+		{err: io.ErrUnexpectedEOF, expected: true},
+		{err: fmt.Errorf("reading: %w", net.ErrClosed), expected: true},
+		{err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, expected: true},
+		{err: &net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, expected: true},
+		{err: errors.New("ssh: disconnect, reason 11: bye"), expected: true},
+		{err: &sourceError{err: io.ErrUnexpectedEOF}, expected: false},
+		{err: &ValidationError{Path: "/file.ach", Err: io.EOF}, expected: false},
+		{err: fmt.Errorf("reading: %w", context.Canceled), expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v", tt.err), func(t *testing.T) {
+			c := &client{cfg: ClientConfig{}}
+			require.Equal(t, tt.expected, c.isConnectionLost(tt.err))
+		})
+	}
+
+	t.Run("isConnectionClosed", func(t *testing.T) {
+		require.False(t, isConnectionClosed(sftp.ErrSSHFxFailure))
+		require.False(t, isConnectionClosed(fmt.Errorf("writing: %w", &sourceError{err: io.EOF})))
+		require.True(t, isConnectionClosed(sftp.ErrSSHFxConnectionLost))
+		require.True(t, isConnectionClosed(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}))
+	})
+
+	t.Run("IsConnectionError", func(t *testing.T) {
+		quota := errors.New("quota exceeded")
+		c := &client{cfg: ClientConfig{
+			IsConnectionError: func(err error) bool {
+				return errors.Is(err, quota)
+			},
+		}}
+		require.True(t, c.isConnectionLost(fmt.Errorf("upload: %w", quota)))
+		require.True(t, c.isConnectionLost(io.EOF))
+		require.False(t, c.isConnectionLost(os.ErrPermission))
+		require.False(t, c.isConnectionLost(nil))
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how many times, and how quickly, a failed attempt is retried.
+//
+// Zero values for MaxAttempts, InitialInterval and Multiplier use the values from DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+
+	// InitialInterval is the delay before the first retry.
+	InitialInterval time.Duration
+
+	// MaxInterval caps the delay between attempts. Zero means no limit.
+	MaxInterval time.Duration
+
+	// Multiplier grows the delay after every retry.
+	Multiplier float64
+
+	// Jitter randomizes each delay by up to this fraction (0.0 to 1.0) in either direction.
+	Jitter float64
+
+	// MaxElapsedTime stops retrying once the next attempt would start after this much time
+	// since the first attempt. Zero means no limit.
+	MaxElapsedTime time.Duration
+
+	// Retryable returns true for errors which should be retried.
+	// DefaultRetryable is used when nil.
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy is used to establish connections when ClientConfig.ConnectRetryPolicy is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	InitialInterval: 250 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+	Multiplier:      2.0,
+	Jitter:          0.2,
+}
+
+// DefaultRetryable returns false for errors which will not succeed by trying again,
+// such as rejected credentials or an unknown host key.
+func DefaultRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case isAuthError(err), errors.Is(err, errNoMatchingHostKeys):
+		return false
+	}
+	return true
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return DefaultRetryPolicy.MaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// backoff returns how long to wait before the given retry, where retry is 1 for the first retry.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	interval := p.InitialInterval
+	if interval <= 0 {
+		interval = DefaultRetryPolicy.InitialInterval
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryPolicy.Multiplier
+	}
+
+	delay := float64(interval) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1) //nolint:gosec
+	}
+	return time.Duration(delay)
+}
+
+// retry calls fn until it succeeds, returns an error which should not be retried or the policy is exhausted.
+// onRetry is called before every retry with the previous error.
+func (p *RetryPolicy) retry(fn func() error, onRetry func(err error)) error {
+	start := time.Now()
+	maxAttempts := p.maxAttempts()
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			delay := p.backoff(attempt - 1)
+			if p.MaxElapsedTime > 0 && time.Since(start)+delay > p.MaxElapsedTime {
+				return err
+			}
+			time.Sleep(delay)
+
+			if onRetry != nil {
+				onRetry(err)
+			}
+		}
+		if err = fn(); err == nil || !p.retryable(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      3,
+	}
+	require.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	require.Equal(t, 300*time.Millisecond, policy.backoff(2))
+	require.Equal(t, 900*time.Millisecond, policy.backoff(3))
+	require.Equal(t, time.Second, policy.backoff(4))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(1)
+		require.GreaterOrEqual(t, delay, 50*time.Millisecond)
+		require.LessOrEqual(t, delay, 150*time.Millisecond)
+	}
+}
+
+func TestRetryPolicy_Retry(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts:     4,
+		InitialInterval: time.Millisecond,
+	}
+
+	t.Run("succeeds", func(t *testing.T) {
+		var calls, retries int
+		err := policy.retry(func() error {
+			calls++
+			if calls < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		}, func(err error) {
+			retries++
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+		require.Equal(t, 2, retries)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		var calls int
+		err := policy.retry(func() error {
+			calls++
+			return fmt.Errorf("attempt %d", calls)
+		}, nil)
+		require.EqualError(t, err, "attempt 4")
+		require.Equal(t, 4, calls)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		var calls int
+		err := policy.retry(func() error {
+			calls++
+			return fmt.Errorf("ssh: handshake failed: %w", errNoMatchingHostKeys)
+		}, nil)
+		require.ErrorIs(t, err, errNoMatchingHostKeys)
+		require.Equal(t, 1, calls)
+
+		calls = 0
+		err = policy.retry(func() error {
+			calls++
+			return errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none password], no supported methods remain")
+		}, nil)
+		require.Error(t, err)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("custom classifier", func(t *testing.T) {
+		policy := *policy
+		policy.Retryable = func(err error) bool {
+			return err.Error() == "try again"
+		}
+
+		var calls int
+		err := policy.retry(func() error {
+			calls++
+			if calls == 1 {
+				return errors.New("try again")
+			}
+			return errors.New("give up")
+		}, nil)
+		require.EqualError(t, err, "give up")
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("max elapsed time", func(t *testing.T) {
+		policy := &RetryPolicy{
+			MaxAttempts:     10,
+			InitialInterval: 20 * time.Millisecond,
+			Multiplier:      1,
+			MaxElapsedTime:  50 * time.Millisecond,
+		}
+
+		var calls int
+		err := policy.retry(func() error {
+			calls++
+			return errors.New("connection refused")
+		}, nil)
+		require.Error(t, err)
+		require.Equal(t, 3, calls)
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ServerInfo describes the remote server as seen while connecting.
+type ServerInfo struct {
+	// Banner is the pre-authentication message sent by the server, if any.
+	Banner string
+
+	// ServerVersion is the SSH identification string, such as "SSH-2.0-OpenSSH_9.6".
+	ServerVersion string
+
+	// Algorithms are the key exchange, host key, cipher and MAC algorithms negotiated with the server.
+	Algorithms ssh.NegotiatedAlgorithms
+}
+
+func newServerInfo(conn *ssh.Client, banner string) ServerInfo {
+	info := ServerInfo{
+		Banner:        banner,
+		ServerVersion: string(conn.ServerVersion()),
+	}
+	if meta, ok := conn.Conn.(ssh.AlgorithmsConnMetadata); ok {
+		info.Algorithms = meta.Algorithms()
+	}
+	return info
+}
+
+// ServerInfo returns details about the server from the most recent connection.
+// The zero value is returned before the first connection.
+func (c *client) ServerInfo() ServerInfo {
+	if info := c.serverInfo.Load(); info != nil {
+		return *info
+	}
+	return ServerInfo{}
+}
+
+func (c *client) logServerInfo(info ServerInfo) {
+	if c.logger == nil {
+		return
+	}
+	algos := info.Algorithms
+	c.logger.Logf("sftp: connected to %s running %s (kex=%s hostkey=%s cipher=%s mac=%s)",
+		c.cfg.Hostname, info.ServerVersion, algos.KeyExchange, algos.HostKey, algos.Write.Cipher, algos.Write.MAC)
+
+	if banner := strings.TrimSpace(info.Banner); banner != "" {
+		c.logger.Logf("sftp: banner from %s: %q", c.cfg.Hostname, banner)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestClient_ServerInfo(t *testing.T) {
+	server := sftptest.NewServer(t)
+	server.Config.BannerCallback = func(conn ssh.ConnMetadata) string {
+		return "maintenance on Sunday\n"
+	}
+
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.Ciphers = []string{ssh.CipherAES256GCM}
+	})
+
+	info := client.ServerInfo()
+	require.Equal(t, "maintenance on Sunday\n", info.Banner)
+	require.Equal(t, "SSH-2.0-Go", info.ServerVersion)
+	require.Equal(t, ssh.KeyAlgoED25519, info.Algorithms.HostKey)
+	require.NotEmpty(t, info.Algorithms.KeyExchange)
+	require.Equal(t, ssh.CipherAES256GCM, info.Algorithms.Read.Cipher)
+	require.Equal(t, ssh.CipherAES256GCM, info.Algorithms.Write.Cipher)
+}
+
+func TestClient_ServerInfoLazyConnect(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.LazyConnect = true
+	})
+	require.Equal(t, sftp.ServerInfo{}, client.ServerInfo())
+
+	require.NoError(t, client.Ping())
+	require.Equal(t, "SSH-2.0-Go", client.ServerInfo().ServerVersion)
+}
+
+func TestMockClient_ServerInfo(t *testing.T) {
+	client := sftp.NewMockClient(t)
+	require.Equal(t, sftp.ServerInfo{}, client.ServerInfo())
+
+	client.Info.ServerVersion = "SSH-2.0-OpenSSH_9.6"
+	require.Equal(t, "SSH-2.0-OpenSSH_9.6", client.ServerInfo().ServerVersion)
+}
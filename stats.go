@@ -0,0 +1,130 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"io"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of a client's activity since it was created.
+type Stats struct {
+	// Operations counts completed operations by name, such as "upload" or "list".
+	Operations map[string]int64
+	Errors     int64
+
+	BytesUploaded   int64
+	BytesDownloaded int64
+
+	// Reconnects counts connections made after the first.
+	Reconnects int64
+
+	LastError   error
+	LastErrorAt time.Time
+
+	// ConnectionAge is how long the current connection has been open, or zero when disconnected.
+	ConnectionAge time.Duration
+
+	// InFlight is the number of operations which have started but not finished.
+	InFlight int64
+}
+
+// statsRecorder collects Stats and is safe for concurrent use.
+// The zero value is ready to use.
+type statsRecorder struct {
+	inFlight        atomic.Int64
+	bytesUploaded   atomic.Int64
+	bytesDownloaded atomic.Int64
+	connections     atomic.Int64
+	connectedAt     atomic.Int64 // unix nanoseconds, zero when disconnected
+
+	mu          sync.Mutex
+	operations  map[string]int64
+	errors      int64
+	lastError   error
+	lastErrorAt time.Time
+}
+
+func (s *statsRecorder) started() {
+	s.inFlight.Add(1)
+}
+
+func (s *statsRecorder) finished(op string, err error) {
+	s.inFlight.Add(-1)
+	s.record(op, err)
+}
+
+// record counts a completed operation and returns err unchanged.
+func (s *statsRecorder) record(op string, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.operations == nil {
+		s.operations = make(map[string]int64)
+	}
+	s.operations[op]++
+
+	if err != nil {
+		s.errors++
+		s.lastError = err
+		s.lastErrorAt = time.Now()
+	}
+	return err
+}
+
+func (s *statsRecorder) uploaded(n int64) {
+	s.bytesUploaded.Add(n)
+}
+
+func (s *statsRecorder) connected() {
+	s.connections.Add(1)
+	s.connectedAt.Store(time.Now().UnixNano())
+}
+
+func (s *statsRecorder) disconnected() {
+	s.connectedAt.Store(0)
+}
+
+// countDownload adds the bytes read from rc as they're consumed.
+func (s *statsRecorder) countDownload(rc io.ReadCloser) io.ReadCloser {
+	return &countingReader{
+		ReadCloser: rc,
+		count:      func(n int) { s.bytesDownloaded.Add(int64(n)) },
+	}
+}
+
+func (s *statsRecorder) snapshot() Stats {
+	stats := Stats{
+		InFlight:        s.inFlight.Load(),
+		BytesUploaded:   s.bytesUploaded.Load(),
+		BytesDownloaded: s.bytesDownloaded.Load(),
+		Reconnects:      max(s.connections.Load()-1, 0),
+	}
+	if at := s.connectedAt.Load(); at > 0 {
+		stats.ConnectionAge = time.Since(time.Unix(0, at))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats.Operations = maps.Clone(s.operations)
+	if stats.Operations == nil {
+		stats.Operations = make(map[string]int64)
+	}
+	stats.Errors = s.errors
+	stats.LastError = s.lastError
+	stats.LastErrorAt = s.lastErrorAt
+
+	return stats
+}
+
+// Stats returns counters of the client's operations and the state of its connection.
+// It is safe to call concurrently with other methods.
+func (c *client) Stats() Stats {
+	return c.stats.snapshot()
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Stats(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, nil)
+
+	stats := client.Stats()
+	require.Empty(t, stats.Operations)
+	require.Positive(t, stats.ConnectionAge)
+	require.Zero(t, stats.Reconnects)
+
+	// Read stats while operations run
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				client.Stats()
+			}
+		}
+	}()
+
+	require.NoError(t, client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	file, err := client.Reader("/file.txt")
+	require.NoError(t, err)
+	_, err = io.ReadAll(file.Contents)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	_, err = client.Reader("/missing.txt")
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+
+	server.DropConnectionsOn("List")
+	_, err = client.ListFiles("/")
+	require.Error(t, err)
+	_, err = client.ListFiles("/")
+	require.NoError(t, err)
+
+	close(done)
+	wg.Wait()
+
+	stats = client.Stats()
+	require.Equal(t, map[string]int64{"upload": 1, "read": 2, "list": 2}, stats.Operations)
+	require.Equal(t, int64(2), stats.Errors)
+	require.Equal(t, int64(8), stats.BytesUploaded)
+	require.Equal(t, int64(8), stats.BytesDownloaded)
+	require.Equal(t, int64(1), stats.Reconnects)
+	require.Zero(t, stats.InFlight)
+	require.ErrorIs(t, stats.LastError, sftp.ErrConnectionLost)
+	require.False(t, stats.LastErrorAt.IsZero())
+
+	require.NoError(t, client.Close())
+	require.Zero(t, client.Stats().ConnectionAge)
+}
+
+func TestMockClient_Stats(t *testing.T) {
+	client := sftp.NewMockClient(t)
+
+	require.NoError(t, client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("contents"))))
+
+	file, err := client.Reader("/file.txt")
+	require.NoError(t, err)
+	_, err = io.ReadAll(file.Contents)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	_, err = client.Open("/missing.txt")
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+
+	stats := client.Stats()
+	require.Equal(t, map[string]int64{"upload": 1, "read": 1, "open": 1}, stats.Operations)
+	require.Equal(t, int64(1), stats.Errors)
+	require.Equal(t, int64(8), stats.BytesUploaded)
+	require.Equal(t, int64(8), stats.BytesDownloaded)
+	require.ErrorIs(t, stats.LastError, sftp.ErrNotFound)
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestClient_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.TracerProvider = provider
+	})
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, "sftp.connect", spans[0].Name)
+	require.Contains(t, spans[0].Attributes, attribute.String("server.address", server.Addr()))
+	require.Contains(t, spans[0].Attributes, attribute.Int("sftp.attempts", 1))
+	exporter.Reset()
+
+	// Spans are children of the caller's span
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "deliver")
+	err := client.UploadFileContext(ctx, "/outbox/file.txt", io.NopCloser(strings.NewReader("contents")))
+	require.NoError(t, err)
+	parent.End()
+
+	spans = exporter.GetSpans()
+	byName := make(map[string]tracetest.SpanStub)
+	var names []string
+	for _, span := range spans {
+		byName[span.Name] = span
+		names = append(names, span.Name)
+	}
+	require.ElementsMatch(t, []string{"sftp.stat", "sftp.mkdir", "sftp.write", "sftp.chmod", "sftp.upload", "deliver"}, names)
+
+	upload := byName["sftp.upload"]
+	require.Equal(t, parent.SpanContext().SpanID(), upload.Parent.SpanID())
+	require.Contains(t, upload.Attributes, attribute.String("sftp.path", "/outbox/file.txt"))
+	require.Contains(t, upload.Attributes, attribute.String("server.address", server.Addr()))
+
+	for _, name := range []string{"sftp.stat", "sftp.mkdir", "sftp.write", "sftp.chmod"} {
+		require.Equal(t, upload.SpanContext.SpanID(), byName[name].Parent.SpanID(), name)
+		require.Equal(t, codes.Unset, byName[name].Status.Code, name)
+	}
+	require.Contains(t, byName["sftp.mkdir"].Attributes, attribute.String("sftp.path", "/outbox/"))
+	require.Contains(t, byName["sftp.write"].Attributes, attribute.Int64("sftp.bytes", 8))
+	exporter.Reset()
+
+	// Reconnecting after a lost connection
+	server.DropConnectionsOn("List")
+	_, err = client.ListFilesContext(context.Background(), "/outbox")
+	require.Error(t, err)
+
+	spans = exporter.GetSpans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "sftp.reconnect", spans[0].Name)
+	require.Equal(t, "sftp.list", spans[1].Name)
+	require.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	require.Equal(t, codes.Error, spans[1].Status.Code)
+	require.NotEmpty(t, spans[1].Events) // recorded error
+}
+
+func TestClient_ContextCanceled(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.UploadFileContext(ctx, "/file.txt", io.NopCloser(strings.NewReader("contents")))
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = client.ReaderContext(ctx, "/file.txt")
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Nothing was written
+	_, err = client.Reader("/file.txt")
+	require.ErrorIs(t, err, sftp.ErrNotFound)
+}
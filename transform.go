@@ -0,0 +1,85 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// Transform is a stage which wraps the contents of a file as it's uploaded or read,
+// such as for hashing, progress reporting or limiting size.
+//
+// If the returned reader implements io.Closer it's closed once the transfer is finished,
+// so stages don't need to close the reader they wrap. An upload fails if closing a stage
+// returns an error.
+type Transform func(r io.Reader) io.Reader
+
+// transformed is the result of running Transforms over a reader.
+type transformed struct {
+	io.Reader
+
+	closers []io.Closer // stages in the order they were applied
+	closed  bool
+}
+
+// applyTransforms wraps r with each transform in order, so the first transform reads from r.
+func applyTransforms(r io.Reader, transforms []Transform) *transformed {
+	out := &transformed{Reader: r}
+	for _, transform := range transforms {
+		next := transform(out.Reader)
+		if next == nil || sameReader(next, out.Reader) {
+			continue
+		}
+		if closer, ok := next.(io.Closer); ok {
+			out.closers = append(out.closers, closer)
+		}
+		out.Reader = next
+	}
+	return out
+}
+
+// sameReader reports if a transform returned its input unchanged, which shouldn't be closed as a stage.
+func sameReader(a, b io.Reader) bool {
+	ta := reflect.TypeOf(a)
+	return ta == reflect.TypeOf(b) && ta.Comparable() && a == b
+}
+
+// Close closes each stage from the last applied to the first. The original reader is not closed.
+func (t *transformed) Close() error {
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+
+	var errs []error
+	for i := len(t.closers) - 1; i >= 0; i-- {
+		if err := t.closers[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// transformDownload wraps rc with transforms, closing every stage and then rc when the result is closed.
+func transformDownload(rc io.ReadCloser, transforms []Transform) io.ReadCloser {
+	if len(transforms) == 0 {
+		return rc
+	}
+	return &transformedReadCloser{
+		transformed: applyTransforms(rc, transforms),
+		rc:          rc,
+	}
+}
+
+type transformedReadCloser struct {
+	*transformed
+	rc io.ReadCloser
+}
+
+func (r *transformedReadCloser) Close() error {
+	return errors.Join(r.transformed.Close(), r.rc.Close())
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Transforms(t *testing.T) {
+	server := sftptest.NewServer(t)
+
+	var uploaded, downloaded countingStage
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.UploadTransforms = []sftp.Transform{upperStage, uploaded.wrap}
+		cfg.DownloadTransforms = []sftp.Transform{downloaded.wrap, reverseStage}
+	})
+	testTransforms(t, client, &uploaded, &downloaded)
+}
+
+func TestMockClient_Transforms(t *testing.T) {
+	var uploaded, downloaded countingStage
+	client := sftp.NewMockClient(t)
+	client.UploadTransforms = []sftp.Transform{upperStage, uploaded.wrap}
+	client.DownloadTransforms = []sftp.Transform{downloaded.wrap, reverseStage}
+
+	testTransforms(t, client, &uploaded, &downloaded)
+}
+
+func testTransforms(t *testing.T, client sftp.Client, uploaded, downloaded *countingStage) {
+	t.Helper()
+
+	require.NoError(t, client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("contents"))))
+	require.Equal(t, 8, uploaded.n)
+	require.True(t, uploaded.closed)
+
+	// Stages run in order: counted as read then reversed
+	require.Equal(t, "STNETNOC", readAll(t, client, "/file.txt"))
+	require.Equal(t, 8, downloaded.n)
+	require.True(t, downloaded.closed)
+
+	// Errors from closing a stage fail the upload
+	uploaded.err = errors.New("too many rows")
+	err := client.UploadFile("/file.txt", io.NopCloser(strings.NewReader("contents")))
+	require.ErrorContains(t, err, "too many rows")
+}
+
+func upperStage(r io.Reader) io.Reader {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return iotestErrReader{err}
+	}
+	return bytes.NewReader(bytes.ToUpper(bs))
+}
+
+func reverseStage(r io.Reader) io.Reader {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return iotestErrReader{err}
+	}
+	for i, j := 0, len(bs)-1; i < j; i, j = i+1, j-1 {
+		bs[i], bs[j] = bs[j], bs[i]
+	}
+	return bytes.NewReader(bs)
+}
+
+type iotestErrReader struct{ err error }
+
+func (r iotestErrReader) Read([]byte) (int, error) { return 0, r.err }
+
+// countingStage counts bytes read through it and records being closed.
+type countingStage struct {
+	r      io.Reader
+	n      int
+	closed bool
+	err    error
+}
+
+func (s *countingStage) wrap(r io.Reader) io.Reader {
+	s.r, s.n, s.closed = r, 0, false
+	return s
+}
+
+func (s *countingStage) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.n += n
+	return n, err
+}
+
+func (s *countingStage) Close() error {
+	s.closed = true
+	return s.err
+}
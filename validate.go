@@ -0,0 +1,211 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// DefaultValidatorMaxSize is the largest file a buffered Validator reads when MaxSize is zero.
+const DefaultValidatorMaxSize = 32 * 1024 * 1024
+
+// Validator inspects the contents of files before they're uploaded, such as checking an ACH
+// file is well formed. Returning an error rejects the upload with a *ValidationError.
+//
+// Buffered validators read the whole file into memory before connecting, so a rejected file
+// never reaches the server. Stream validators read the file as it's uploaded to a temporary
+// file, which is removed if validation fails. In both cases nothing is left at the upload path.
+type Validator struct {
+	// Pattern limits the validator to filenames matching a filepath.Match pattern, such as "*.ach".
+	// Every file is validated when empty.
+	Pattern string
+
+	// Stream validates contents as they're uploaded instead of buffering them first.
+	Stream bool
+
+	// MaxSize is the largest file buffered for validation, larger files are rejected.
+	// Defaults to DefaultValidatorMaxSize. Stream validators only read the first MaxSize
+	// bytes of each file, or the entire file when zero.
+	MaxSize int64
+
+	// Validate is given the path and contents of the file being uploaded.
+	Validate func(path string, contents io.Reader) error
+}
+
+// ValidationError is returned when a Validator rejects an upload.
+type ValidationError struct {
+	Path    string
+	Pattern string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("sftp: %s failed validation: %v", e.Path, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (v Validator) matches(path string) bool {
+	if v.Pattern == "" {
+		return true
+	}
+	ok, _ := filepath.Match(v.Pattern, filepath.Base(path))
+	return ok
+}
+
+func (v Validator) maxSize() int64 {
+	if v.MaxSize > 0 {
+		return v.MaxSize
+	}
+	return DefaultValidatorMaxSize
+}
+
+func checkValidators(validators []Validator) error {
+	for i, v := range validators {
+		if v.Validate == nil {
+			return fmt.Errorf("validator #%d is missing Validate", i)
+		}
+		if _, err := filepath.Match(v.Pattern, ""); err != nil {
+			return fmt.Errorf("validator #%d pattern %q: %w", i, v.Pattern, err)
+		}
+		if v.MaxSize < 0 {
+			return fmt.Errorf("validator #%d has negative MaxSize", i)
+		}
+	}
+	return nil
+}
+
+// hasStreamValidators reports if any stream validators apply to path.
+func hasStreamValidators(validators []Validator, path string) bool {
+	for _, v := range validators {
+		if v.Stream && v.matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBuffered runs the buffered validators which match path and returns the contents to upload.
+// contents is returned unchanged when no validators match.
+func validateBuffered(validators []Validator, path string, contents io.Reader) (io.Reader, error) {
+	var matched []Validator
+	var limit int64
+	for _, v := range validators {
+		if !v.Stream && v.matches(path) {
+			matched = append(matched, v)
+			limit = max(limit, v.maxSize())
+		}
+	}
+	if len(matched) == 0 {
+		return contents, nil
+	}
+
+	bs, err := io.ReadAll(io.LimitReader(contents, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("sftp: reading %s for validation: %w", path, err)
+	}
+	for _, v := range matched {
+		if int64(len(bs)) > v.maxSize() {
+			return nil, &ValidationError{
+				Path:    path,
+				Pattern: v.Pattern,
+				Err:     fmt.Errorf("larger than %d bytes", v.maxSize()),
+			}
+		}
+		if err := v.Validate(path, bytes.NewReader(bs)); err != nil {
+			return nil, &ValidationError{Path: path, Pattern: v.Pattern, Err: err}
+		}
+	}
+	return bytes.NewReader(bs), nil
+}
+
+// validateStream wraps r so the stream validators matching path read what's read from r.
+// The returned finish function waits for validation, which is cancelled when err is non-nil.
+func validateStream(validators []Validator, path string, r io.Reader) (io.Reader, func(err error) error) {
+	var readers []*validatingReader
+	for _, v := range validators {
+		if v.Stream && v.matches(path) {
+			vr := startValidation(v, path, r)
+			readers = append(readers, vr)
+			r = vr
+		}
+	}
+	return r, func(err error) error {
+		var errs []error
+		for _, vr := range readers {
+			if verr := vr.finish(err); verr != nil && err == nil {
+				errs = append(errs, verr)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// validatingReader copies what's read into a pipe which the Validator reads from in a goroutine.
+type validatingReader struct {
+	r  io.Reader
+	pw *io.PipeWriter
+
+	limit   int64 // zero is unlimited
+	written int64
+
+	done chan struct{}
+	err  error
+}
+
+func startValidation(v Validator, path string, r io.Reader) *validatingReader {
+	pr, pw := io.Pipe()
+	vr := &validatingReader{
+		r:     r,
+		pw:    pw,
+		limit: v.MaxSize,
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(vr.done)
+		if err := v.Validate(path, pr); err != nil {
+			vr.err = &ValidationError{Path: path, Pattern: v.Pattern, Err: err}
+			pr.CloseWithError(vr.err) // stop the upload
+			return
+		}
+		// Let the upload continue when Validate returns before reading everything
+		io.Copy(io.Discard, pr)
+	}()
+	return vr
+}
+
+func (v *validatingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	if n > 0 && (v.limit == 0 || v.written < v.limit) {
+		chunk := p[:n]
+		if v.limit > 0 && v.written+int64(n) > v.limit {
+			chunk = chunk[:v.limit-v.written]
+		}
+		if _, werr := v.pw.Write(chunk); werr != nil {
+			<-v.done
+			if v.err != nil {
+				return n, v.err
+			}
+			return n, werr
+		}
+		v.written += int64(len(chunk))
+		if v.limit > 0 && v.written >= v.limit {
+			v.pw.Close()
+		}
+	}
+	return n, err
+}
+
+func (v *validatingReader) finish(err error) error {
+	v.pw.CloseWithError(err)
+	<-v.done
+	return v.err
+}
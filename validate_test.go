@@ -0,0 +1,105 @@
+// Copyright 2022 The Moov Authors
+// Use of this source code is governed by an Apache License
+// license that can be found in the LICENSE file.
+
+package go_sftp_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	sftp "github.com/moov-io/go-sftp"
+	"github.com/moov-io/go-sftp/internal/sftptest"
+
+	"github.com/moov-io/base/log"
+	"github.com/stretchr/testify/require"
+)
+
+// achHeader rejects files which don't start with an ACH file header record.
+func achHeader(path string, contents io.Reader) error {
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(contents, header); err != nil || !bytes.Equal(header, []byte("101")) {
+		return errors.New("missing file header")
+	}
+	return nil
+}
+
+func TestClient_Validators(t *testing.T) {
+	server := sftptest.NewServer(t)
+	client := newTestClient(t, server, func(cfg *sftp.ClientConfig) {
+		cfg.Validators = []sftp.Validator{
+			{Pattern: "*.ach", Validate: achHeader},
+			{Pattern: "*.csv", MaxSize: 10, Validate: func(string, io.Reader) error { return nil }},
+			{Pattern: "*.stream", Stream: true, MaxSize: 3, Validate: achHeader},
+		}
+	})
+	puts := server.Requests("Put")
+
+	// Buffered validators reject files before anything is written
+	err := client.UploadFile("/outbox/bad.ach", io.NopCloser(strings.NewReader("5200 batch")))
+	var verr *sftp.ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Equal(t, "/outbox/bad.ach", verr.Path)
+	require.Equal(t, "*.ach", verr.Pattern)
+	require.ErrorContains(t, err, "missing file header")
+
+	err = client.UploadFile("/outbox/big.csv", io.NopCloser(strings.NewReader(strings.Repeat("a,b\n", 10))))
+	require.ErrorAs(t, err, &verr)
+	require.ErrorContains(t, err, "larger than 10 bytes")
+	require.Equal(t, puts, server.Requests("Put"))
+
+	// Stream validators reject files as they're uploaded without leaving a partial file
+	contents := "5200 batch" + strings.Repeat("x", 100000)
+	err = client.UploadFile("/outbox/bad.stream", io.NopCloser(strings.NewReader(contents)))
+	require.ErrorAs(t, err, &verr)
+	require.Equal(t, "*.stream", verr.Pattern)
+
+	files, err := client.ListFiles("/outbox")
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	// Valid and unmatched files are uploaded
+	require.NoError(t, client.UploadFile("/outbox/good.ach", io.NopCloser(strings.NewReader("101 header"))))
+	contents = "101 header" + strings.Repeat("x", 100000)
+	require.NoError(t, client.UploadFile("/outbox/good.stream", io.NopCloser(strings.NewReader(contents))))
+	require.NoError(t, client.UploadFile("/outbox/notes.txt", io.NopCloser(strings.NewReader("anything"))))
+	require.Greater(t, server.Requests("Put"), puts)
+
+	require.Equal(t, "101 header", readAll(t, client, "/outbox/good.ach"))
+	require.Equal(t, contents, readAll(t, client, "/outbox/good.stream"))
+
+	files, err = client.ListFiles("/outbox")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"/outbox/good.ach", "/outbox/good.stream", "/outbox/notes.txt"}, files)
+
+	_, err = sftp.NewClient(log.NewTestLogger(), &sftp.ClientConfig{
+		Hostname:    server.Addr(),
+		LazyConnect: true,
+		Validators:  []sftp.Validator{{Pattern: "[", Validate: achHeader}},
+	})
+	require.ErrorContains(t, err, "validator #0 pattern")
+}
+
+func TestMockClient_Validators(t *testing.T) {
+	client := sftp.NewMockClient(t)
+	client.Validators = []sftp.Validator{
+		{Pattern: "*.ach", Validate: achHeader},
+		{Pattern: "*.stream", Stream: true, Validate: achHeader},
+	}
+
+	for _, path := range []string{"/outbox/bad.ach", "/outbox/bad.stream"} {
+		err := client.UploadFile(path, io.NopCloser(strings.NewReader("5200 batch")))
+		var verr *sftp.ValidationError
+		require.ErrorAs(t, err, &verr)
+	}
+
+	files, err := client.ListFiles("/outbox")
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	require.NoError(t, client.UploadFile("/outbox/good.stream", io.NopCloser(strings.NewReader("101 header"))))
+	require.Equal(t, "101 header", readAll(t, client, "/outbox/good.stream"))
+}